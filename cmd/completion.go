@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cozees/cook/pkg/cook/ast"
+	"github.com/cozees/cook/pkg/cook/parser"
+	"github.com/cozees/cook/pkg/runtime/args"
+	"github.com/cozees/cook/pkg/runtime/function"
+)
+
+// completion script delegate the work to "cook __complete WORD..." which print one candidate per line
+var completionScripts = map[string]string{
+	"bash": `# bash completion for cook, add the line below to ~/.bashrc
+# source <(cook completion bash)
+_cook_completion() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null 2>&1; then
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -W "$(cook __complete "${words[@]:1:cword}" 2>/dev/null)" -- "$cur"))
+    if declare -F __ltrim_colon_completions >/dev/null 2>&1; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+complete -o default -F _cook_completion cook
+`,
+	"zsh": `#compdef cook
+# zsh completion for cook, add the line below to ~/.zshrc
+# source <(cook completion zsh)
+_cook() {
+    local -a candidates
+    candidates=("${(@f)$(cook __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if [[ -n "${candidates[1]}" ]]; then
+        compadd -Q -- "${candidates[@]}"
+    else
+        _files
+    fi
+}
+if [[ "${funcstack[1]}" == "_cook" ]]; then
+    _cook "$@"
+else
+    compdef _cook cook
+fi
+`,
+	"fish": `# fish completion for cook, save the output to ~/.config/fish/completions/cook.fish
+function __cook_complete
+    set -l words (commandline -opc) (commandline -ct)
+    cook __complete $words[2..-1] 2>/dev/null
+end
+complete -c cook -f -a '(__cook_complete)'
+`,
+}
+
+var (
+	subCommands = []string{"completion", "fmt", "help", "lsp", "repl", "vet"}
+	mainOptions = []string{"--dry-run", "--error-format", "--force", "--jobs", "--list", "--trace", "--vars-file", "--watch", "-c", "-j"}
+)
+
+func printCompletion(w io.Writer, shell string) error {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return fmt.Errorf("completion for shell %s is not supported, use bash, zsh or fish", shell)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// completeWords return candidates for the last word, the words are everything given after cook in
+// the command line.
+func completeWords(words []string) []string {
+	cur := ""
+	if len(words) > 0 {
+		cur, words = words[len(words)-1], words[:len(words)-1]
+	}
+	if len(words) > 0 {
+		switch words[len(words)-1] {
+		case "-c", "-j", "--jobs", "--vars-file", "--watch":
+			// a file, a glob or a number, let the shell decide
+			return nil
+		case "--error-format":
+			return filterPrefix([]string{"json", "text"}, cur)
+		}
+	}
+
+	var candidates []string
+	switch {
+	case len(words) == 0 && strings.HasPrefix(cur, "@"):
+		for _, name := range function.Names() {
+			candidates = append(candidates, "@"+name)
+		}
+	case len(words) > 0 && strings.HasPrefix(words[0], "@"):
+		// function flags
+		if fn := function.GetFunction(words[0][1:]); fn != nil && strings.HasPrefix(cur, "-") {
+			for _, fl := range fn.Flags().Flags {
+				if fl.Short != "" {
+					candidates = append(candidates, "-"+fl.Short)
+				}
+				candidates = append(candidates, "--"+fl.Long)
+			}
+		}
+	case len(words) == 1 && words[0] == "help":
+		candidates = append(candidates, "targets")
+		for _, name := range function.Names() {
+			candidates = append(candidates, "@"+name)
+		}
+	case len(words) == 1 && words[0] == "completion":
+		candidates = []string{"bash", "fish", "zsh"}
+	case len(words) > 0 && words[0] == "fmt":
+		if !strings.HasPrefix(cur, "-") {
+			// Cookfile to format, let the shell decide
+			return nil
+		}
+		candidates = []string{"-check", "-d", "-w"}
+	case len(words) > 0 && words[0] == "vet":
+		if !strings.HasPrefix(cur, "-") {
+			return nil
+		}
+		candidates = []string{"--error-format"}
+	case len(words) > 0 && words[0] == "repl" && !strings.HasPrefix(cur, "-"):
+		// repl does not accept a target
+		return nil
+	case len(words) > 0 && (words[0] == "help" || words[0] == "completion"):
+		return nil
+	case strings.HasPrefix(cur, "--") && strings.Contains(cur, ":"):
+		return args.CompleteVariableType(cur)
+	case strings.HasPrefix(cur, "-"):
+		candidates = mainOptions
+	default:
+		if len(words) == 0 {
+			candidates = append(candidates, subCommands...)
+		}
+		candidates = append(candidates, completeTargets(words)...)
+	}
+	return filterPrefix(candidates, cur)
+}
+
+// completeTargets return every target declared in the Cookfile given via -c or the default one.
+func completeTargets(words []string) []string {
+	file := "Cookfile"
+	for i := 0; i+1 < len(words); i++ {
+		if words[i] == "-c" {
+			file = words[i+1]
+		}
+	}
+	if _, err := os.Stat(file); err != nil {
+		return nil
+	}
+	cook, err := parser.NewParser().Parse(file)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	targets := make(map[string]*ast.Target)
+	for _, t := range cook.Targets() {
+		names = append(names, t.Name())
+		targets[t.Name()] = t
+	}
+	// the arguments of the last target given can be completed as well
+	for i := len(words) - 1; i >= 0; i-- {
+		if t := targets[words[i]]; t != nil {
+			for _, p := range t.Params {
+				names = append(names, p.Name+"=")
+			}
+			break
+		}
+	}
+	return names
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	var result []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
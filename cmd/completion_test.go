@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCookfile = "testdata/Cookfile"
+
+type completeCase struct {
+	words      []string
+	candidates []string
+}
+
+var completeWordsCases = []*completeCase{
+	{ // case 1
+		words:      []string{"-c", testCookfile, ""},
+		candidates: []string{"build", "deploy"},
+	},
+	{ // case 2
+		words:      []string{"-c", testCookfile, "dep"},
+		candidates: []string{"deploy"},
+	},
+	{ // case 3
+		words:      []string{"-c", testCookfile, "deploy", ""},
+		candidates: []string{"build", "deploy", "env=", "replicas="},
+	},
+	{ // case 4
+		words:      []string{"-c", testCookfile, "deploy", "re"},
+		candidates: []string{"replicas="},
+	},
+	{ // case 5
+		words:      []string{"co"},
+		candidates: []string{"completion"},
+	},
+	{ // case 6
+		words:      []string{"--d"},
+		candidates: []string{"--dry-run"},
+	},
+	{ // case 7
+		words:      []string{"-"},
+		candidates: mainOptions,
+	},
+	{ // case 8
+		words:      []string{"--error-format", ""},
+		candidates: []string{"json", "text"},
+	},
+	{ // case 9
+		words: []string{"--watch", ""},
+	},
+	{ // case 10
+		words: []string{"-c", ""},
+	},
+	{ // case 11
+		words:      []string{"--VERSION:"},
+		candidates: []string{"--VERSION:i", "--VERSION:f", "--VERSION:s", "--VERSION:b", "--VERSION:a"},
+	},
+	{ // case 12
+		words:      []string{"@templ"},
+		candidates: []string{"@template"},
+	},
+	{ // case 13
+		words:      []string{"@template", "-"},
+		candidates: []string{"-i", "--include"},
+	},
+	{ // case 14
+		words: []string{"@template", "conf"},
+	},
+	{ // case 15
+		words:      []string{"help", "tar"},
+		candidates: []string{"targets"},
+	},
+	{ // case 16
+		words:      []string{"completion", ""},
+		candidates: []string{"bash", "fish", "zsh"},
+	},
+	{ // case 17
+		words: []string{"completion", "bash", ""},
+	},
+	{ // case 18
+		words:      []string{"fmt", "-"},
+		candidates: []string{"-check", "-d", "-w"},
+	},
+	{ // case 19
+		words: []string{"fmt", "Cook"},
+	},
+	{ // case 20
+		words:      []string{"vet", "-"},
+		candidates: []string{"--error-format"},
+	},
+	{ // case 21
+		words: []string{"repl", "bu"},
+	},
+	{ // case 22
+		words:      []string{"repl", "--f"},
+		candidates: []string{"--force"},
+	},
+	{ // case 23
+		words: []string{"-c", "testdata/missing", "bu"},
+	},
+}
+
+func TestCompleteWords(t *testing.T) {
+	for i, tc := range completeWordsCases {
+		t.Logf("TestCompleteWords case #%d", i+1)
+		assert.Equal(t, tc.candidates, completeWords(tc.words))
+	}
+}
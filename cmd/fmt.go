@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cozees/cook/pkg/cook/parser"
+	"github.com/cozees/cook/pkg/cook/token"
+	"github.com/cozees/cook/pkg/runtime/args"
+)
+
+const (
+	// fmtMaxLength is the line length from which array and map literal is written one element per line
+	fmtMaxLength = 100
+	// diffContext is the number of unchanged lines printed around a change
+	diffContext = 3
+)
+
+// format run cook fmt and return the exit status.
+func format(opts *args.MainOptions) int {
+	fopts, status := opts.Format, 0
+	for _, file := range fopts.Files {
+		stat, err := os.Stat(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			status = 1
+			continue
+		}
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			status = 1
+			continue
+		}
+		result, err := parser.Format(token.NewFile(file, len(src)), src, fmtMaxLength)
+		if err != nil {
+			reportError(opts, err)
+			status = 1
+			continue
+		}
+		changed := !bytes.Equal(src, result)
+		if fopts.Check && changed {
+			fmt.Println(file)
+			status = 1
+		}
+		if fopts.Diff && changed {
+			fmt.Print(unifiedDiff(file, src, result))
+		}
+		if fopts.Write && !fopts.Check && changed {
+			if err = ioutil.WriteFile(file, result, stat.Mode().Perm()); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				status = 1
+			}
+		}
+		if !fopts.Write && !fopts.Diff && !fopts.Check {
+			os.Stdout.Write(result)
+		}
+	}
+	return status
+}
+
+type diffLine struct {
+	op   byte // ' ' unchanged, '-' removed or '+' added
+	text string
+	a, b int // zero-based line number in the original and the formatted source
+}
+
+func splitLines(src []byte) []string {
+	s := strings.TrimSuffix(string(src), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// unifiedDiff return the difference between the original and the formatted source in unified
+// format using the longest common subsequence of their lines.
+func unifiedDiff(name string, original, formatted []byte) string {
+	x, y := splitLines(original), splitLines(formatted)
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, diffLine{' ', x[i], i, j})
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', x[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', y[j], i, j})
+			j++
+		}
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "--- %s.orig\n+++ %s\n", name, name)
+	for start := 0; start < len(lines); {
+		// find the next change then extend the hunk while the following change is close enough
+		for start < len(lines) && lines[start].op == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+		end := start
+		for k := start; k < len(lines) && k <= end+2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+		from, to := start-diffContext, end+diffContext+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(lines) {
+			to = len(lines)
+		}
+		acount, bcount := 0, 0
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				acount++
+			}
+			if l.op != '-' {
+				bcount++
+			}
+		}
+		astart, bstart := lines[from].a+1, lines[from].b+1
+		if acount == 0 {
+			astart--
+		}
+		if bcount == 0 {
+			bstart--
+		}
+		fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", astart, acount, bstart, bcount)
+		for _, l := range lines[from:to] {
+			sb.WriteByte(l.op)
+			sb.WriteString(l.text)
+			sb.WriteByte('\n')
+		}
+		start = to
+	}
+	return sb.String()
+}
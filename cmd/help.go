@@ -1,17 +1,27 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"strings"
 
+	"github.com/cozees/cook/pkg/cook/ast"
+	"github.com/cozees/cook/pkg/cook/token"
 	"github.com/cozees/cook/pkg/runtime/args"
 	"github.com/cozees/cook/pkg/runtime/function"
 )
 
 var mainFlags = &args.Flags{
 	FuncName: "cook",
-	Usage: `cook --VAR VALUE [TARGET ...]
-			cook help [@FUNCTION]`,
+	Usage: `cook [--force] [--dry-run] [--trace] [-j JOBS] [--watch GLOB] [--error-format text|json] [--vars-file FILE] --VAR VALUE [TARGET ...]
+			cook --list
+			cook completion bash|zsh|fish
+			cook fmt [-w] [-d] [-check] [COOKFILE ...]
+			cook vet [--error-format text|json] [COOKFILE ...]
+			cook repl [-c COOKFILE] --VAR VALUE
+			cook lsp
+			cook help [@FUNCTION | targets]`,
 	ShortDesc: `Cook interpreter to execute cookfile.`,
 	Example: `cook --INPUT 1.32 sample_target
 	          cook sample_target
@@ -25,8 +35,41 @@ var mainFlags = &args.Flags{
 }
 
 const (
-	helpDesc = `Print cook help to standard console if no function given otherwise print function help out instead.`
-	varDesc  = `Define dynamic global variable via argument. By default, a dynamic global variable can be provided via
+	jobsDesc = `Execute up to JOBS independent targets at the same time. A target is started only after all of its
+				dependencies are finished and each line it writes is prefixed with the target name.`
+	dryRunDesc = `Print every external command, built-in function call and redirect with its arguments expanded
+				  instead of executing it. Built-in functions which only compute a value are still executed.`
+	traceDesc = `Print every statement, external command, built-in function call, target and function with its
+				 arguments evaluated to standard error before executing it. The same can be enable for a Cookfile
+				 by placing the trace directive at the top of the file.`
+	listDesc = `Print every target and function declared in the Cookfile and its included files along with the
+				 comment placed directly above it and where it is declared. Same as cook help targets.`
+	complDesc = `Print a script for bash, zsh or fish which complete targets of the Cookfile, built-in functions,
+				 flags of the built-in function and cook options, e.g. source <(cook completion bash).`
+	watchDesc = `Keep running and execute the targets again whenever a file matched by GLOB or the Cookfile is added,
+				 removed or modified. GLOB support ** to match any number of directories and the flag can be given
+				 more than once. A failed execution is reported and cook keep watching.`
+	errFmtDesc = `Print errors as text along with the offending source line or as json, one object per line which
+				 contain file, line, column, code, severity, message and stack, for editors and CI.`
+	fmtDesc = `Format the Cookfiles, Cookfile in the current directory by default, and print the result to standard
+			   output. Flag -w write the result back to the file, -d print the difference instead and -check
+			   only report the files which are not formatted and exit with status 1 if there is any.
+			   Comments are kept and long array or map literal is wrapped one element per line.`
+	vetDesc = `Report mistakes in the Cookfiles, Cookfile in the current directory by default, without executing
+			   them: calling an undefined target or function, using a variable before it is assigned, break or
+			   continue outside of a for loop, an unknown flag of a built-in function and a wrong number of
+			   arguments given to a function. Exit with status 1 if there is any error, warnings alone does not fail.`
+	replDesc = `Read statements and expressions from standard input and print the value of each one along with its
+				type. Variables are kept between lines and a block is continued until its braces are closed. The
+				Cookfile, if there is one, is loaded first so its targets and functions can be called.`
+	lspDesc = `Serve the Language Server Protocol over standard input and output which provide diagnostics,
+			   go to definition, hover and completion of Cookfile to an editor.`
+	varsFileDesc = `Declare the top-level keys of a JSON, YAML or TOML file, chosen by its extension .json, .yaml, .yml
+				    or .toml, as variables. The flag can be given more than once, a variable given by --VAR take
+				    precedence over the files.`
+	forceDesc = `Execute targets even if their output files are up to date with their input files.`
+	helpDesc  = `Print cook help to standard console if no function given otherwise print function help out instead.`
+	varDesc   = `Define dynamic global variable via argument. By default, a dynamic global variable can be provided via
 				environment variable however its a read-only variable. Variable define via argument is allowed to be
 				change during execution.`
 )
@@ -38,7 +81,67 @@ func PrintHelp(f *args.FunctionMeta) {
 	} else {
 		io.Copy(os.Stdout, mainFlags.HelpFlagVisitor(false, "", func(fw args.FlagWriter) {
 			fw(12, "", "help", "", helpDesc)
+			fw(12, "", "force", "", forceDesc)
+			fw(12, "", "dry-run", "", dryRunDesc)
+			fw(12, "", "trace", "", traceDesc)
+			fw(12, "", "list", "", listDesc)
+			fw(12, "", "completion", "", complDesc)
+			fw(12, "", "fmt", "", fmtDesc)
+			fw(12, "", "vet", "", vetDesc)
+			fw(12, "", "repl", "", replDesc)
+			fw(12, "", "lsp", "", lspDesc)
+			fw(12, "j", "jobs", "", jobsDesc)
+			fw(12, "", "watch", "", watchDesc)
+			fw(12, "", "error-format", "", errFmtDesc)
+			fw(12, "", "vars-file", "", varsFileDesc)
 			fw(12, "", "[VARIABLE]", "", varDesc)
 		}))
 	}
 }
+
+// PrintTargets write every target and function declared in Cookfile with its documentation.
+func PrintTargets(w io.Writer, cook ast.Cook) {
+	type entry struct {
+		name string
+		pos  token.Position
+		doc  string
+	}
+	var targets, fns []*entry
+	width := 0
+	for _, t := range cook.Targets() {
+		name := t.Signature()
+		if deps := t.Dependencies(); len(deps) > 0 {
+			name += ": " + strings.Join(deps, " ")
+		}
+		targets = append(targets, &entry{name: name, pos: t.Position(), doc: t.Doc})
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	for _, fn := range cook.Functions() {
+		name := fn.Signature()
+		fns = append(fns, &entry{name: name, pos: fn.Position(), doc: fn.Doc})
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	section := func(title string, entries []*entry) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintln(w, title)
+		for _, e := range entries {
+			fmt.Fprintf(w, "    %-*s  %s:%d\n", width, e.name, e.pos.Filename, e.pos.Line)
+			if e.doc != "" {
+				for _, line := range strings.Split(e.doc, "\n") {
+					fmt.Fprintf(w, "        %s\n", line)
+				}
+			}
+		}
+	}
+	section("TARGETS", targets)
+	if len(targets) > 0 && len(fns) > 0 {
+		fmt.Fprintln(w)
+	}
+	section("FUNCTIONS", fns)
+}
@@ -5,18 +5,49 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 
+	"github.com/cozees/cook/pkg/cook/ast"
 	"github.com/cozees/cook/pkg/cook/parser"
+	"github.com/cozees/cook/pkg/cook/token"
+	cookErrors "github.com/cozees/cook/pkg/errors"
+	"github.com/cozees/cook/pkg/lsp"
 	"github.com/cozees/cook/pkg/runtime/args"
 	"github.com/cozees/cook/pkg/runtime/function"
 )
 
 func main() {
 	opts, err := args.ParseMainArgument(os.Args[1:])
+	if err == nil {
+		err = loadVarsFiles(opts)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
+	} else if opts.Completion != "" {
+		if err = printCompletion(os.Stdout, opts.Completion); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	} else if opts.CompleteWords != nil {
+		for _, candidate := range completeWords(opts.CompleteWords) {
+			fmt.Println(candidate)
+		}
+		os.Exit(0)
+	} else if opts.IsLSP {
+		if err = lsp.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	} else if opts.Format != nil {
+		os.Exit(format(opts))
+	} else if opts.Vet != nil {
+		os.Exit(vet(opts))
+	} else if opts.IsRepl {
+		os.Exit(repl(opts))
 	} else if opts.IsHelp {
 		PrintHelp(opts.FuncMeta)
 		os.Exit(0)
@@ -25,20 +56,79 @@ func main() {
 		os.Exit(0)
 	}
 
+	if len(opts.Watch) > 0 && !opts.IsList {
+		watch(opts)
+	}
+
 	p := parser.NewParser()
 	cook, err := p.Parse(opts.Cookfile)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-	} else if len(opts.Targets) > 0 {
-		err = cook.ExecuteWithTarget(opts.Args, opts.Targets...)
-	} else {
-		err = cook.Execute(opts.Args)
+		reportError(opts, err)
+		os.Exit(1)
 	}
-	if err != nil {
+	if opts.IsList {
+		PrintTargets(os.Stdout, cook)
+		os.Exit(0)
+	}
+	if err = execute(cook, opts); err != nil {
+		reportError(opts, err)
+		os.Exit(ast.ExitCode(err))
+	}
+}
+
+// reportError print err to standard error in the format requested by --error-format.
+func reportError(opts *args.MainOptions, err error) {
+	if rerr := cookErrors.Report(os.Stderr, err, opts.ErrorFormat); rerr != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 	}
 }
 
+// loadVarsFiles declare the top-level keys of the files given by --vars-file as variables, a file
+// given later override the variables of the previous one and --NAME override them all.
+func loadVarsFiles(opts *args.MainOptions) error {
+	if len(opts.VarsFiles) == 0 {
+		return nil
+	}
+	vars := make(map[string]interface{})
+	for _, file := range opts.VarsFiles {
+		v, err := function.DecodeFile(file)
+		if err != nil {
+			return err
+		}
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return fmt.Errorf("%s: variables file must contain a map", file)
+		}
+		for key, val := range m {
+			name, ok := key.(string)
+			if !ok || !token.IsIdentifier(name) {
+				return fmt.Errorf("%s: key %v is not a valid variable name", file, key)
+			}
+			vars[name] = val
+		}
+	}
+	for name, val := range opts.Args {
+		vars[name] = val
+	}
+	opts.Args = vars
+	return nil
+}
+
+func execute(cook ast.Cook, opts *args.MainOptions) error {
+	cook.SetOptions(&ast.Options{
+		Force:      opts.Force,
+		Dir:        filepath.Dir(opts.Cookfile),
+		Jobs:       opts.Jobs,
+		DryRun:     opts.DryRun,
+		Trace:      opts.Trace,
+		TargetArgs: opts.TargetArgs,
+	})
+	if len(opts.Targets) > 0 {
+		return cook.ExecuteWithTarget(opts.Args, opts.Targets...)
+	}
+	return cook.Execute(opts.Args)
+}
+
 func executeFunction(opts *args.MainOptions) {
 	fn := function.GetFunction(opts.FuncMeta.Name)
 	if fn == nil {
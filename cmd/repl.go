@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/cozees/cook/pkg/cook/ast"
+	"github.com/cozees/cook/pkg/cook/parser"
+	"github.com/cozees/cook/pkg/cook/token"
+	"github.com/cozees/cook/pkg/runtime/args"
+	"golang.org/x/term"
+)
+
+const (
+	replPrompt   = "cook> "
+	replContinue = "...   "
+	// replHistory is the file in the home directory which keep the lines entered in the REPL
+	replHistory    = ".cook_history"
+	replHistoryMax = 1000
+)
+
+// lineReader read the input of the REPL line by line.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	Close() error
+}
+
+// repl run cook repl and return the exit status.
+func repl(opts *args.MainOptions) int {
+	cook, err := replCook(opts)
+	if err != nil {
+		reportError(opts, err)
+		return 1
+	}
+	cook.SetOptions(&ast.Options{DryRun: opts.DryRun, Trace: opts.Trace})
+	in, err := ast.NewInterpreter(cook, opts.Args)
+	if err != nil {
+		reportError(opts, err)
+		return 1
+	}
+	var input lineReader
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		input = newTermReader(fd)
+	} else {
+		input = &plainReader{r: bufio.NewReader(os.Stdin)}
+	}
+	defer input.Close()
+
+	for {
+		src, err := readStatement(input)
+		if err == io.EOF {
+			return 0
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		} else if strings.TrimSpace(src) == "" {
+			continue
+		}
+		block, x, err := parser.ParseStatement(cook, token.NewFile("repl", len(src)), []byte(src))
+		if err != nil {
+			reportError(opts, err)
+			continue
+		}
+		var v interface{}
+		var kind reflect.Kind
+		if x != nil {
+			v, kind, err = in.EvaluateExpr(x)
+		} else {
+			v, kind, err = in.Evaluate(block)
+		}
+		if err != nil {
+			reportError(opts, err)
+		} else if kind != reflect.Invalid {
+			fmt.Printf("%s (%s)\n", ast.FormatValue(v), ast.KindName(kind))
+		}
+	}
+}
+
+// replCook parse the Cookfile so its targets and functions can be called, otherwise the REPL start
+// with an empty Cook.
+func replCook(opts *args.MainOptions) (ast.Cook, error) {
+	if opts.Cookfile == "" {
+		return ast.NewCook(), nil
+	}
+	return parser.NewParser().Parse(opts.Cookfile)
+}
+
+// readStatement read a line and the following lines as long as a brace, a bracket or a parenthesis
+// is left open so a block can be written across multiple lines.
+func readStatement(input lineReader) (string, error) {
+	sb := &strings.Builder{}
+	prompt := replPrompt
+	for {
+		line, err := input.ReadLine(prompt)
+		if err != nil {
+			if err == io.EOF && sb.Len() > 0 {
+				// evaluate the incomplete input so its error is reported
+				return sb.String(), nil
+			}
+			return "", err
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+		if openBrackets(sb.String()) <= 0 {
+			return sb.String(), nil
+		}
+		prompt = replContinue
+	}
+}
+
+// openBrackets return the number of brace, bracket and parenthesis which is not yet closed. The one
+// in a string literal or a comment is not counted.
+func openBrackets(src string) int {
+	open := 0
+	var quote byte
+	for i := 0; i < len(src); i++ {
+		switch ch := src[i]; {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case ch == '{' || ch == '[' || ch == '(':
+			open++
+		case ch == '}' || ch == ']' || ch == ')':
+			open--
+		}
+	}
+	return open
+}
+
+type plainReader struct {
+	r *bufio.Reader
+}
+
+func (pr *plainReader) ReadLine(prompt string) (string, error) {
+	line, err := pr.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (pr *plainReader) Close() error { return nil }
+
+// termReader read a line from a terminal with line editing and history. The history of the previous
+// session is replayed to the terminal while its output is muted as the terminal does not allow to
+// add a line to its history directly.
+type termReader struct {
+	fd       int
+	t        *term.Terminal
+	muted    bool
+	history  []string
+	fhistory string
+}
+
+func newTermReader(fd int) *termReader {
+	tr := &termReader{fd: fd}
+	if home, err := os.UserHomeDir(); err == nil {
+		tr.fhistory = filepath.Join(home, replHistory)
+		if b, err := ioutil.ReadFile(tr.fhistory); err == nil {
+			tr.history = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+			if len(tr.history) == 1 && tr.history[0] == "" {
+				tr.history = nil
+			}
+		}
+	}
+	replay := &bytes.Buffer{}
+	for _, line := range tr.history {
+		replay.WriteString(line)
+		replay.WriteByte('\r')
+	}
+	tr.t = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{io.MultiReader(replay, os.Stdin), tr}, replPrompt)
+	tr.muted = true
+	for range tr.history {
+		tr.t.ReadLine()
+	}
+	tr.muted = false
+	return tr
+}
+
+func (tr *termReader) Write(b []byte) (int, error) {
+	if tr.muted {
+		return len(b), nil
+	}
+	return os.Stdout.Write(b)
+}
+
+func (tr *termReader) ReadLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(tr.fd)
+	if err != nil {
+		return "", err
+	}
+	// the terminal is restored before the line is evaluated, so the output of a command is not
+	// written in raw mode
+	defer term.Restore(tr.fd, state)
+	tr.t.SetPrompt(prompt)
+	line, err := tr.t.ReadLine()
+	if err == nil && line != "" {
+		tr.history = append(tr.history, line)
+	}
+	return line, err
+}
+
+// Close save the most recent lines to the history file.
+func (tr *termReader) Close() error {
+	if tr.fhistory == "" || len(tr.history) == 0 {
+		return nil
+	}
+	if len(tr.history) > replHistoryMax {
+		tr.history = tr.history[len(tr.history)-replHistoryMax:]
+	}
+	return ioutil.WriteFile(tr.fhistory, []byte(strings.Join(tr.history, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/cozees/cook/pkg/cook/ast"
+	"github.com/cozees/cook/pkg/cook/parser"
+	cookErrors "github.com/cozees/cook/pkg/errors"
+	"github.com/cozees/cook/pkg/runtime/args"
+)
+
+// vet report the mistakes found in the Cookfiles and return the exit status which is 1 if any of
+// them is an error, warnings alone does not fail.
+func vet(opts *args.MainOptions) int {
+	status := 0
+	for _, file := range opts.Vet {
+		cook, err := parser.NewParser().Parse(file)
+		if err != nil {
+			reportError(opts, err)
+			status = 1
+			continue
+		}
+		diags := ast.Vet(cook)
+		if len(diags) == 0 {
+			continue
+		}
+		errs := &cookErrors.CookError{}
+		for _, d := range diags {
+			errs.StackError(d)
+			if d.Severity == cookErrors.SeverityError {
+				status = 1
+			}
+		}
+		reportError(opts, errs)
+	}
+	return status
+}
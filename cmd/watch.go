@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cozees/cook/pkg/cook/parser"
+	"github.com/cozees/cook/pkg/runtime/args"
+	"github.com/cozees/cook/pkg/runtime/glob"
+)
+
+const (
+	// watchInterval is how often the watched files are checked for changes
+	watchInterval = 500 * time.Millisecond
+	// watchDebounce is how long the watched files must stay unchanged before targets are executed
+	watchDebounce = 300 * time.Millisecond
+)
+
+// watch execute the targets then keep polling the files matched by the watch patterns, the
+// Cookfile itself and the files it include. Whenever a file is added, removed or modified the Cookfile is parsed again and
+// the targets are executed once the files stop changing. A failure is reported but never stop
+// the watcher, the process run until it is interrupted.
+func watch(opts *args.MainOptions) {
+	patterns := append([]string{opts.Cookfile}, opts.Watch...)
+	run := func() glob.Snapshot {
+		cook, err := parser.NewParser().Parse(opts.Cookfile)
+		if err == nil {
+			// an include directive may be added or removed between runs
+			patterns = append(append([]string{opts.Cookfile}, cook.Includes()...), opts.Watch...)
+			err = execute(cook, opts)
+		}
+		if err != nil {
+			reportError(opts, err)
+		}
+		// take the snapshot after executing so files written by the targets do not trigger another run
+		snapshot, err := glob.Take(patterns...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "watching %d files for changes\n", len(snapshot))
+		return snapshot
+	}
+
+	last := run()
+	for {
+		time.Sleep(watchInterval)
+		current, err := glob.Take(patterns...)
+		if err != nil || !current.Changed(last) {
+			continue
+		}
+		for {
+			time.Sleep(watchDebounce)
+			next, err := glob.Take(patterns...)
+			if err != nil || !next.Changed(current) {
+				break
+			}
+			current = next
+		}
+		last = run()
+	}
+}
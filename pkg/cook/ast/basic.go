@@ -1,15 +1,20 @@
 package ast
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cozees/cook/pkg/cook/token"
 	cookErrors "github.com/cozees/cook/pkg/errors"
@@ -173,17 +178,32 @@ type (
 		Name         string
 		OutputResult bool
 		FuncLit      *Function
+		// Result is set when a command is written as ##command, the call return a map of its
+		// stdout, stderr, exit code and duration and a non-zero exit code is not an error.
+		Result bool
+		// Options holds env, dir and timeout option given to an external command, e.g.
+		// #go dir='src' timeout=60 'build'
+		Options []*NamedArg
 
 		// use internally for share argument with pipe expression
-		pipeCmdArgs     string
 		pipeBuiltInArgs *args.FunctionArg
 	}
 
+	// A node represent an argument given by name to a function, e.g. @fn 1 b=2
+	NamedArg struct {
+		*Base
+		Name string
+		X    Node
+	}
+
 	// A node represent pipe expression
 	Pipe struct {
 		*Base
 		X *Call
 		Y Node
+		// OutputResult is set when the output of the last call is used as a value rather than
+		// written to the standard output
+		OutputResult bool
 	}
 
 	// A node represent redirect read file expression <
@@ -233,11 +253,24 @@ type (
 		*Base
 		Ident SettableNode
 		Fn    *Function
-		to    SettableNode // use by assign statement
+		// Mapper is the function given by name instead of a function literal, e.g. A(double)
+		Mapper Node
+		to     SettableNode // use by assign statement
+	}
+
+	// A node represent a function literal, e.g. (a, b) => a + b, its value is a closure
+	FunctionLit struct {
+		*Base
+		Fn *Function
 	}
 )
 
-func (b *Base) Position() token.Position { return b.File.Position(b.Offset) }
+func (b *Base) Position() token.Position {
+	if b == nil || b.File == nil {
+		return token.Position{}
+	}
+	return b.File.Position(b.Offset)
+}
 func (b *Base) ErrPos() string {
 	p := b.Position()
 	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
@@ -259,7 +292,7 @@ func (bl *BasicLit) Evaluate(ctx Context) (v interface{}, k reflect.Kind, err er
 	case token.STRING:
 		v, k = bl.Lit, reflect.String
 	default:
-		return nil, 0, fmt.Errorf("%s: invalid literal value %s of type %s", bl.ErrPos(), bl.Lit, bl.Kind)
+		return nil, 0, cookErrors.Errorf(bl.Position(), cookErrors.CodeType, "invalid literal value %s of type %s", bl.Lit, bl.Kind)
 	}
 	if err != nil {
 		return nil, 0, err
@@ -272,7 +305,12 @@ func (bl *BasicLit) Evaluate(ctx Context) (v interface{}, k reflect.Kind, err er
 // look into environment varaible and return it value if founded otherwise a nil and invalid kind is
 // return instead.
 func (id *Ident) Evaluate(ctx Context) (v interface{}, k reflect.Kind, err error) {
-	v, k, _ = ctx.GetVariable(id.Name)
+	if v, k, _ = ctx.GetVariable(id.Name); k == reflect.Invalid {
+		// a function declared in Cookfile can be used as a value by its name
+		if fn := ctx.GetFunction(id.Name); fn != nil {
+			return &Closure{Fn: fn}, reflect.Func, nil
+		}
+	}
 	return
 }
 
@@ -299,7 +337,7 @@ func (cd *Conditional) Evaluate(ctx Context) (v interface{}, k reflect.Kind, err
 	if v, k, err = cd.Cond.Evaluate(ctx); err != nil {
 		return nil, 0, err
 	} else if k != reflect.Bool {
-		return nil, 0, fmt.Errorf("%s: expression %s is not a valid boolean expression", cd.Cond.ErrPos(), cd.Cond)
+		return nil, 0, cookErrors.Errorf(cd.Cond.Position(), cookErrors.CodeType, "expression %s is not a valid boolean expression", cd.Cond)
 	} else if v.(bool) {
 		return cd.True.Evaluate(ctx)
 	} else {
@@ -444,7 +482,7 @@ func (tc *TypeCast) Evaluate(ctx Context) (v interface{}, k reflect.Kind, err er
 				return strconv.FormatBool(iv.(bool)), tk, nil
 			}
 		}
-		err = fmt.Errorf("%s cannot cast %v to type %s", tc.ErrPos(), iv, tk)
+		err = cookErrors.Errorf(tc.Position(), cookErrors.CodeType, "cannot cast %v to type %s", iv, tk)
 	} else {
 		v, k = iv, ik
 	}
@@ -626,19 +664,15 @@ func (ix *Index) evaluateInternal(ctx Context, setVal interface{}) (interface{},
 		switch vk {
 		case reflect.Slice:
 			if ik != reflect.Int64 {
-				return nil, 0, fmt.Errorf("%s: index value is not integer", ix.ErrPos())
+				return nil, 0, cookErrors.Errorf(ix.Position(), cookErrors.CodeType, "index value is not integer")
 			} else if ind := int(i.(int64)); ind < 0 || ind >= vv.Len() {
-				return nil, 0, fmt.Errorf("%s: index %d out of range, array length %d", ix.ErrPos(), ind, vv.Len())
+				return nil, 0, cookErrors.Errorf(ix.Position(), cookErrors.CodeIndex, "index %d out of range, array length %d", ind, vv.Len())
 			} else if setVal != nil {
 				vv.Index(ind).Set(reflect.ValueOf(setVal))
 				return nil, 0, nil
 			} else {
 				vi := vv.Index(ind)
-				k := vi.Kind()
-				if k == reflect.Interface {
-					k = vi.Elem().Kind()
-				}
-				return vi.Interface(), k, nil
+				return vi.Interface(), elemKind(vi), nil
 			}
 		case reflect.Map:
 			key := reflect.ValueOf(i)
@@ -647,14 +681,10 @@ func (ix *Index) evaluateInternal(ctx Context, setVal interface{}) (interface{},
 				return nil, 0, nil
 			} else {
 				vi := vv.MapIndex(key)
-				k := vi.Kind()
-				if k == reflect.Interface {
-					k = vi.Elem().Kind()
-				}
 				if (vi != reflect.Value{}) && vi.CanInterface() {
-					return vi.Interface(), k, nil
+					return vi.Interface(), elemKind(vi), nil
 				} else {
-					return nil, 0, fmt.Errorf("map index %v is not exist", i)
+					return nil, 0, cookErrors.Errorf(ix.Position(), cookErrors.CodeIndex, "map index %v is not exist", i)
 				}
 			}
 		case TransformSlice:
@@ -821,55 +851,60 @@ func (c *Call) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
 		return c.FuncLit.Execute(ctx, c.Args)
 	}
 
+	if ctx.Options().DryRun {
+		if desc, err := c.dryRun(ctx); err != nil || desc != "" {
+			if v, k, err := printDryRun(ctx, desc, err); err != nil || !c.Result {
+				return v, k, err
+			}
+			// pretend that the command succeed so the result can still be inspected
+			return commandResult("", "", 0, 0), reflect.Map, nil
+		}
+	}
+
 	switch c.Kind {
 	case token.HASH:
-		if args, err := c.args(ctx); err != nil {
+		cmd, timeout, err := c.command(ctx)
+		if err != nil {
 			return nil, 0, err
-		} else {
-			cmd := exec.Command(c.Name, args...)
-			dir, err := os.Getwd()
-			if err != nil {
+		}
+		cmd.Stdin = os.Stdin
+		if c.Result {
+			return c.runResult(ctx, cmd, timeout)
+		} else if !c.OutputResult {
+			cmd.Stdout = ctx.Stdout()
+			cmd.Stderr = ctx.Stderr()
+			if err = c.run(ctx, cmd, timeout); err != nil {
 				return nil, 0, err
-			}
-			cmd.Dir = dir
-			if c.pipeCmdArgs != "" {
-				if w, err := cmd.StdinPipe(); err != nil {
-					return nil, 0, err
-				} else if _, err = w.Write([]byte(c.pipeCmdArgs)); err != nil {
-					w.Close()
-					return nil, 0, err
-				} else if err = w.Close(); err != nil {
-					return nil, 0, err
-				}
 			} else {
-				cmd.Stdin = os.Stdin
+				return "", reflect.String, nil
 			}
-			if !c.OutputResult {
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				if err = cmd.Run(); err != nil {
-					return nil, 0, err
-				} else {
-					return "", reflect.String, nil
-				}
+		} else {
+			var result bytes.Buffer
+			cmd.Stdout = &result
+			if err = c.run(ctx, cmd, timeout); err != nil {
+				return nil, 0, err
 			} else {
-				result, err := cmd.Output()
-				if err != nil {
-					return nil, 0, err
-				} else {
-					return string(result), reflect.String, nil
-				}
+				return result.String(), reflect.String, nil
 			}
 		}
 	case token.AT:
+		// a variable holding a function shadow a target, a built-in command or a function
+		if v, k, _ := ctx.GetVariable(c.Name); k == reflect.Func {
+			if v, k, err := v.(*Closure).Execute(ctx, c.Args); err != nil {
+				d := cookErrors.NewDiagnostic(c.Position(), cookErrors.CodeRuntime, err)
+				d.PushFrame("function", c.Name, c.Position())
+				return nil, 0, d
+			} else {
+				return v, k, nil
+			}
+		}
 		// priority target, built-in command, developer defined function
 		t := ctx.GetTarget(c.Name)
 		if t != nil {
 			if args, err := c.funcArgs(ctx); err != nil {
 				return nil, 0, err
 			} else {
-				t.Execute(ctx, args)
-				return nil, 0, nil
+				return nil, 0, t.Execute(ctx, args)
 			}
 		}
 		// command
@@ -878,8 +913,17 @@ func (c *Call) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
 			if args, err := c.funcArgs(ctx); err != nil {
 				return nil, 0, err
 			} else {
-				if v, err := f.Apply(args); err != nil {
-					return nil, 0, fmt.Errorf("%s: %w", c.ErrPos(), err)
+				if ctx.Tracing() {
+					ctx.Trace(c.Position(), describeBuiltIn(c.Name, args))
+				}
+				var v interface{}
+				if of, ok := f.(function.OutputFunction); ok {
+					v, err = of.ApplyOutput(args, ctx.Stdout())
+				} else {
+					v, err = f.Apply(args)
+				}
+				if err != nil {
+					return nil, 0, cookErrors.NewDiagnostic(c.Position(), cookErrors.CodeFunction, err)
 				} else {
 					return v, reflect.ValueOf(v).Kind(), nil
 				}
@@ -888,15 +932,174 @@ func (c *Call) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
 		// function
 		fn := ctx.GetFunction(c.Name)
 		if fn == nil {
-			return nil, 0, fmt.Errorf("target or function %s is not exist", c.Name)
+			return nil, 0, cookErrors.Errorf(c.Position(), cookErrors.CodeUndefined, "target or function %s is not exist", c.Name)
+		} else if v, k, err := fn.Execute(ctx, c.Args); err != nil {
+			d := cookErrors.NewDiagnostic(c.Position(), cookErrors.CodeRuntime, err)
+			d.PushFrame("function", c.Name, c.Position())
+			return nil, 0, d
 		} else {
-			return fn.Execute(ctx, c.Args)
+			return v, k, nil
 		}
 	}
 	// parser should ensure it
 	panic(fmt.Sprintf("invalid call expression %s", c.Kind))
 }
 
+// runResult run the command and return a map of the output written to stdout and stderr, its exit
+// code and the duration in seconds. The output is also written to the context's writers when the
+// result is not used.
+func (c *Call) runResult(ctx Context, cmd *exec.Cmd, timeout time.Duration) (interface{}, reflect.Kind, error) {
+	var stdout, stderr bytes.Buffer
+	if c.OutputResult {
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	} else {
+		cmd.Stdout = io.MultiWriter(&stdout, ctx.Stdout())
+		cmd.Stderr = io.MultiWriter(&stderr, ctx.Stderr())
+	}
+	code := 0
+	start := time.Now()
+	if err := c.run(ctx, cmd, timeout); err != nil {
+		var ee *exec.ExitError
+		if !errors.As(err, &ee) {
+			return nil, 0, err
+		}
+		code = ee.ExitCode()
+	}
+	return commandResult(stdout.String(), stderr.String(), code, time.Since(start)), reflect.Map, nil
+}
+
+// command return the external command ready to be started along with its timeout if any.
+func (c *Call) command(ctx Context) (*exec.Cmd, time.Duration, error) {
+	args, err := c.args(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ctx.Tracing() {
+		ctx.Trace(c.Position(), describeCommand(c.Name, args))
+	}
+	cmd := exec.Command(c.Name, args...)
+	if cmd.Dir, err = os.Getwd(); err != nil {
+		return nil, 0, err
+	}
+	if cmd.Env, err = environ(ctx); err != nil {
+		return nil, 0, cookErrors.NewDiagnostic(c.Position(), cookErrors.CodeType, err)
+	}
+	timeout, err := c.applyOptions(ctx, cmd)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cmd, timeout, nil
+}
+
+// run the command and wait for it to exit. If timeout is given, the command is started in its own
+// process group and the whole group is killed when the command does not exit in time.
+func (c *Call) run(ctx Context, cmd *exec.Cmd, timeout time.Duration) error {
+	if err := c.start(ctx, cmd, timeout); err != nil {
+		return err
+	}
+	return c.wait(cmd, timeout)
+}
+
+func (c *Call) start(ctx Context, cmd *exec.Cmd, timeout time.Duration) (err error) {
+	if job := jobOf(ctx); job != nil {
+		err = job.start(cmd)
+	} else {
+		if timeout > 0 {
+			setProcessGroup(cmd)
+		}
+		err = cmd.Start()
+	}
+	if err != nil {
+		return cookErrors.NewDiagnostic(c.Position(), cookErrors.CodeCommand, err)
+	}
+	return nil
+}
+
+// wait for the started command to exit, the command is killed if it does not exit before timeout.
+func (c *Call) wait(cmd *exec.Cmd, timeout time.Duration) error {
+	if timeout <= 0 {
+		if err := cmd.Wait(); err != nil {
+			return cookErrors.NewDiagnostic(c.Position(), cookErrors.CodeCommand, err)
+		}
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return cookErrors.NewDiagnostic(c.Position(), cookErrors.CodeCommand, err)
+		}
+		return nil
+	case <-time.After(timeout):
+		killProcessGroup(cmd)
+		<-done
+		return cookErrors.Errorf(c.Position(), cookErrors.CodeCommand, "command %s timed out after %s", c.Name, timeout)
+	}
+}
+
+// applyOptions set the environment variables and the working directory given as option of the
+// command and return its timeout if any.
+func (c *Call) applyOptions(ctx Context, cmd *exec.Cmd) (timeout time.Duration, err error) {
+	for _, opt := range c.Options {
+		v, k, err := opt.X.Evaluate(ctx)
+		if err != nil {
+			return 0, err
+		}
+		switch opt.Name {
+		case "env":
+			if k != reflect.Map {
+				return 0, cookErrors.Errorf(opt.Position(), cookErrors.CodeType, "option env require map, given %s", FormatValue(v))
+			}
+			if cmd.Env == nil {
+				cmd.Env = os.Environ()
+			}
+			for mk, mv := range v.(map[interface{}]interface{}) {
+				name, err := convertToString(ctx, mk, reflect.ValueOf(mk).Kind())
+				if err != nil {
+					return 0, cookErrors.NewDiagnostic(opt.Position(), cookErrors.CodeType, err)
+				}
+				value, err := convertToString(ctx, mv, reflect.ValueOf(mv).Kind())
+				if err != nil {
+					return 0, cookErrors.NewDiagnostic(opt.Position(), cookErrors.CodeType, err)
+				}
+				cmd.Env = append(cmd.Env, name+"="+value)
+			}
+		case "dir":
+			if k != reflect.String {
+				return 0, cookErrors.Errorf(opt.Position(), cookErrors.CodeType, "option dir require string, given %s", FormatValue(v))
+			}
+			if dir := v.(string); filepath.IsAbs(dir) {
+				cmd.Dir = dir
+			} else {
+				cmd.Dir = filepath.Join(cmd.Dir, dir)
+			}
+		case "timeout":
+			switch k {
+			case reflect.Int64:
+				timeout = time.Duration(v.(int64)) * time.Second
+			case reflect.Float64:
+				timeout = time.Duration(v.(float64) * float64(time.Second))
+			default:
+				return 0, cookErrors.Errorf(opt.Position(), cookErrors.CodeType, "option timeout require number of seconds, given %s", FormatValue(v))
+			}
+			if timeout <= 0 {
+				return 0, cookErrors.Errorf(opt.Position(), cookErrors.CodeType, "option timeout must be greater than 0, given %s", FormatValue(v))
+			}
+		}
+	}
+	return timeout, nil
+}
+
+func commandResult(stdout, stderr string, code int, duration time.Duration) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		"stdout":   stdout,
+		"stderr":   stderr,
+		"code":     int64(code),
+		"duration": duration.Seconds(),
+	}
+}
+
 func (c *Call) args(ctx Context) ([]string, error) {
 	args := make([]string, 0, len(c.Args))
 	for _, arg := range c.Args {
@@ -910,7 +1113,7 @@ func (c *Call) args(ctx Context) ([]string, error) {
 				}
 			default:
 				if v, err = convertToString(ctx, v, vk); err != nil {
-					return nil, fmt.Errorf("%s: %w", c.Base.ErrPos(), err)
+					return nil, cookErrors.NewDiagnostic(c.Position(), cookErrors.CodeType, err)
 				}
 				args = append(args, v.(string))
 			}
@@ -921,12 +1124,23 @@ func (c *Call) args(ctx Context) ([]string, error) {
 
 func (c *Call) funcArgs(ctx Context) ([]*args.FunctionArg, error) {
 	sargs := make([]*args.FunctionArg, 0, len(c.Args))
+	isTarget, keepArray := ctx.GetTarget(c.Name) != nil, false
+	if f := ctx.GetCommand(c.Name); !isTarget && f != nil {
+		// a built-in function which accept an array as a value, e.g. @jsonencode, receive it as is
+		keepArray = f.Flags().KeepArray
+	}
 	for _, arg := range c.Args {
-		if v, vk, err := arg.Evaluate(ctx); err != nil {
+		if na, ok := arg.(*NamedArg); ok && isTarget {
+			if v, vk, err := na.X.Evaluate(ctx); err != nil {
+				return nil, err
+			} else {
+				sargs = append(sargs, &args.FunctionArg{Name: na.Name, Val: v, Kind: vk})
+			}
+		} else if v, vk, err := arg.Evaluate(ctx); err != nil {
 			return nil, err
 		} else {
-			switch vk {
-			case reflect.Array, reflect.Slice:
+			switch {
+			case (vk == reflect.Array || vk == reflect.Slice) && !keepArray:
 				sargs = expandArrayToFuncArgs(ctx, reflect.ValueOf(v), sargs)
 			default:
 				sargs = append(sargs, &args.FunctionArg{Val: v, Kind: vk})
@@ -939,45 +1153,169 @@ func (c *Call) funcArgs(ctx Context) ([]*args.FunctionArg, error) {
 	return sargs, nil
 }
 
-func (c *Call) setPipeArgument(ctx Context, v interface{}, k reflect.Kind) (err error) {
-	if c.Kind == token.HASH {
-		if c.pipeCmdArgs, err = convertToString(ctx, v, k); err != nil {
-			return err
-		}
-	} else {
-		c.pipeBuiltInArgs = &args.FunctionArg{Val: v, Kind: k}
-	}
-	return nil
+// Evaluate return an error as named argument can only be given to a function or a target declared
+// in Cookfile, the function or the target evaluate its value instead.
+func (na *NamedArg) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
+	return nil, 0, cookErrors.Errorf(na.Position(), cookErrors.CodeArgument, "named argument %s is only allowed when calling a function or a target", na.Name)
 }
 
+// Pipe Evaluate run each call of the pipe in order, the output of a call is the input of the next
+// one. Consecutive external commands are started together and connected with an operating system
+// pipe, the output of a built-in function is given to the next call as its last argument.
 func (pp *Pipe) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
-	pp.X.OutputResult = true
-	if result, kind, err := pp.X.Evaluate(ctx); err != nil {
-		return nil, 0, err
-	} else if pp.Y != nil {
-		if c, ok := pp.Y.(*Call); ok {
-			if err = c.setPipeArgument(ctx, result, kind); err != nil {
+	if ctx.Options().DryRun {
+		desc, err := describe(ctx, pp)
+		return printDryRun(ctx, desc, err)
+	}
+	if ctx.Tracing() {
+		ctx.Trace(pp.X.Position(), pp.String())
+	}
+	calls, redirect := pp.calls()
+	var v interface{}
+	var k reflect.Kind
+	var err error
+	for i := 0; i < len(calls); {
+		if calls[i].Kind != token.HASH {
+			if i > 0 {
+				calls[i].pipeBuiltInArgs = &args.FunctionArg{Val: v, Kind: k}
+			}
+			if i == len(calls)-1 && redirect != nil {
+				return redirect.Evaluate(ctx)
+			}
+			calls[i].OutputResult = pp.OutputResult || i < len(calls)-1
+			if v, k, err = calls[i].Evaluate(ctx); err != nil {
 				return nil, 0, err
 			}
-			c.OutputResult = true
-			return c.Evaluate(ctx)
-		} else if p, ok := pp.Y.(*Pipe); ok {
-			if err = p.X.setPipeArgument(ctx, result, kind); err != nil {
+			i++
+			continue
+		}
+		// group consecutive external commands
+		j := i + 1
+		for j < len(calls) && calls[j].Kind == token.HASH {
+			j++
+		}
+		var stdin io.Reader = os.Stdin
+		if i > 0 {
+			if v, err = convertToString(ctx, v, k); err != nil {
+				return nil, 0, cookErrors.NewDiagnostic(calls[i].Position(), cookErrors.CodeType, err)
+			}
+			stdin = strings.NewReader(v.(string))
+		}
+		var result *bytes.Buffer
+		var stdout io.Writer
+		switch {
+		case j == len(calls) && redirect != nil:
+			files, err := redirect.openFiles(ctx)
+			if err != nil {
 				return nil, 0, err
 			}
-			return p.Evaluate(ctx)
-		} else if rd, ok := pp.Y.(*RedirectTo); ok {
-			if c, ok := rd.Caller.(*Call); ok {
-				if err = c.setPipeArgument(ctx, result, kind); err != nil {
-					return nil, 0, err
-				}
-				c.OutputResult = true
-				return rd.Evaluate(ctx)
+			defer closeFiles(files)
+			writers := make([]io.Writer, len(files))
+			for n, f := range files {
+				writers[n] = f
 			}
+			stdout = io.MultiWriter(writers...)
+		case j == len(calls) && !pp.OutputResult:
+			stdout = ctx.Stdout()
+		default:
+			result = &bytes.Buffer{}
+			stdout = result
 		}
-		panic("cook internal error: Pipe support only call, redirect and pipe expression itself")
-	} else {
-		return result, kind, nil
+		if err = runPipeline(ctx, calls[i:j], stdin, stdout); err != nil {
+			return nil, 0, err
+		}
+		if result != nil {
+			v, k = result.String(), reflect.String
+		} else {
+			v, k = nil, 0
+		}
+		i = j
+	}
+	return v, k, nil
+}
+
+// calls return every call of the pipe in order and the redirect of the last call if any.
+func (pp *Pipe) calls() ([]*Call, *RedirectTo) {
+	calls := []*Call{pp.X}
+	for y := pp.Y; y != nil; {
+		switch n := y.(type) {
+		case *Call:
+			return append(calls, n), nil
+		case *Pipe:
+			calls = append(calls, n.X)
+			y = n.Y
+		case *RedirectTo:
+			return append(calls, n.Caller.(*Call)), n
+		default:
+			panic("cook internal error: Pipe support only call, redirect and pipe expression itself")
+		}
+	}
+	return calls, nil
+}
+
+// runPipeline start every command at once, the standard output of a command is connected to the
+// standard input of the next one. Like a shell, the exit status of the pipeline is the exit status of
+// the last command, an earlier command fail only if it cannot be started or is timed out.
+func runPipeline(ctx Context, calls []*Call, stdin io.Reader, stdout io.Writer) error {
+	cmds := make([]*exec.Cmd, len(calls))
+	timeouts := make([]time.Duration, len(calls))
+	for i, c := range calls {
+		var err error
+		if cmds[i], timeouts[i], err = c.command(ctx); err != nil {
+			return err
+		}
+		cmds[i].Stderr = ctx.Stderr()
+	}
+	cmds[0].Stdin = stdin
+	cmds[len(cmds)-1].Stdout = stdout
+	// parent's copy of the pipe must be closed once the commands are started otherwise the reader
+	// never receive the end of file.
+	var ends []*os.File
+	defer func() { closeFiles(ends) }()
+	for i := 0; i < len(cmds)-1; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		ends = append(ends, r, w)
+		cmds[i].Stdout, cmds[i+1].Stdin = w, r
+	}
+	for i, cmd := range cmds {
+		if err := calls[i].start(ctx, cmd, timeouts[i]); err != nil {
+			for _, started := range cmds[:i] {
+				started.Process.Kill()
+				started.Wait()
+			}
+			return err
+		}
+	}
+	closeFiles(ends)
+	ends = nil
+	errs := make([]error, len(cmds))
+	var wg sync.WaitGroup
+	for i, cmd := range cmds {
+		wg.Add(1)
+		go func(i int, cmd *exec.Cmd) {
+			defer wg.Done()
+			errs[i] = calls[i].wait(cmd, timeouts[i])
+		}(i, cmd)
+	}
+	wg.Wait()
+	if err := errs[len(errs)-1]; err != nil {
+		return err
+	}
+	var ee *exec.ExitError
+	for _, err := range errs {
+		if err != nil && !errors.As(err, &ee) {
+			return err
+		}
+	}
+	return nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
 	}
 }
 
@@ -996,6 +1334,10 @@ func (rf *ReadFrom) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
 
 // WriteTo Evaluate write/append the data to the file
 func (rt *RedirectTo) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
+	if ctx.Options().DryRun {
+		desc, err := describe(ctx, rt)
+		return printDryRun(ctx, desc, err)
+	}
 	files, err := stringOf(ctx, rt.Files...)
 	if err != nil {
 		return nil, 0, err
@@ -1042,23 +1384,41 @@ tryReader:
 	} else {
 		return nil, 0, fmt.Errorf("write to file unsupport type %s", vk)
 	}
+	fs, err := rt.openFiles(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer closeFiles(fs)
 	var writer []io.Writer
+	for _, f := range fs {
+		writer = append(writer, f)
+	}
+	_, err = io.Copy(io.MultiWriter(writer...), reader)
+	return nil, 0, err
+}
+
+// openFiles open every file to be written or appended to.
+func (rt *RedirectTo) openFiles(ctx Context) ([]*os.File, error) {
+	files, err := stringOf(ctx, rt.Files...)
+	if err != nil {
+		return nil, err
+	}
+	flags := os.O_WRONLY | os.O_CREATE
+	if rt.Append {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	var fs []*os.File
 	for _, f := range files {
-		flags := os.O_WRONLY | os.O_CREATE
-		if rt.Append {
-			flags |= os.O_APPEND
-		} else {
-			flags |= os.O_TRUNC
-		}
 		w, err := os.OpenFile(f, flags, 0700)
 		if err != nil {
-			return nil, 0, err
+			closeFiles(fs)
+			return nil, err
 		}
-		defer w.Close()
-		writer = append(writer, w)
+		fs = append(fs, w)
 	}
-	_, err = io.Copy(io.MultiWriter(writer...), reader)
-	return nil, 0, err
+	return fs, nil
 }
 
 // Paran Evaluate execute inner node and return it's response
@@ -1066,6 +1426,11 @@ func (p *Paren) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
 	return p.Inner.Evaluate(ctx)
 }
 
+// FunctionLit Evaluate return a closure which capture the current scope
+func (fl *FunctionLit) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
+	return &Closure{Fn: fl.Fn, scope: ctx.CurrentScope()}, reflect.Func, nil
+}
+
 //
 func (un *Unary) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
 	v, vk, err := un.X.Evaluate(ctx)
@@ -1182,12 +1547,39 @@ func (b *Binary) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
 	}
 }
 
+// closure return the function which transform each element
+func (t *Transformation) closure(ctx Context) (*Closure, error) {
+	if t.Mapper == nil {
+		return &Closure{Fn: t.Fn}, nil
+	}
+	v, k, err := t.Mapper.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	} else if k != reflect.Func {
+		return nil, cookErrors.Errorf(t.Mapper.Position(), cookErrors.CodeType, "%s is not a function", t.Mapper)
+	}
+	return v.(*Closure), nil
+}
+
+// apply call the function with the index or the key and the value of an element, a function given
+// by name which accept a single argument receive the value only.
+func (t *Transformation) apply(ctx Context, cl *Closure, farg argumentSetter) (interface{}, reflect.Kind, error) {
+	if t.Mapper != nil && !cl.Fn.Variadic && len(cl.Fn.Args) == 1 {
+		return cl.execute(ctx, 1, nil, func(int) (interface{}, reflect.Kind, error) { return farg(1) })
+	}
+	return cl.execute(ctx, 2, nil, farg)
+}
+
 // Transformation Evaluate apply tranform on an array or map
 func (t *Transformation) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
 	tv, k, err := t.Ident.Evaluate(ctx)
 	if err != nil {
 		return nil, 0, err
 	}
+	cl, err := t.closure(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
 	// if equal then we should update value in place or self update otherwise
 	// store transform meta instead
 	if t.to.IsEqual(t.Ident) {
@@ -1197,7 +1589,7 @@ func (t *Transformation) Evaluate(ctx Context) (interface{}, reflect.Kind, error
 			vv := reflect.ValueOf(tv)
 			for i := 0; i < vv.Len(); i++ {
 				indv := vv.Index(i)
-				v, _, err := t.Fn.internalExecute(ctx, 2, func(vi int) (interface{}, reflect.Kind, error) {
+				v, _, err := t.apply(ctx, cl, func(vi int) (interface{}, reflect.Kind, error) {
 					if vi == 0 {
 						return int64(i), reflect.Int64, nil
 					} else {
@@ -1215,7 +1607,7 @@ func (t *Transformation) Evaluate(ctx Context) (interface{}, reflect.Kind, error
 			keys := vv.MapKeys()
 			for _, key := range keys {
 				indv := vv.MapIndex(key)
-				v, _, err := t.Fn.internalExecute(ctx, 2, func(vi int) (interface{}, reflect.Kind, error) {
+				v, _, err := t.apply(ctx, cl, func(vi int) (interface{}, reflect.Kind, error) {
 					if vi == 0 {
 						return key.Interface(), key.Kind(), nil
 					} else {
@@ -1249,7 +1641,7 @@ func (t *Transformation) Evaluate(ctx Context) (interface{}, reflect.Kind, error
 					return v.Interface(), v.Kind(), nil
 				},
 				Value: func(ctx Context, i, val interface{}) (interface{}, reflect.Kind, error) {
-					return t.Fn.internalExecute(ctx, 2, func(iv int) (interface{}, reflect.Kind, error) {
+					return t.apply(ctx, cl, func(iv int) (interface{}, reflect.Kind, error) {
 						if iv == 0 {
 							return i.(int64), reflect.Int64, nil
 						} else {
@@ -1271,7 +1663,7 @@ func (t *Transformation) Evaluate(ctx Context) (interface{}, reflect.Kind, error
 					return v.Interface(), v.Kind(), nil
 				},
 				Value: func(ctx Context, i, val interface{}) (interface{}, reflect.Kind, error) {
-					return t.Fn.internalExecute(ctx, 2, func(iv int) (interface{}, reflect.Kind, error) {
+					return t.apply(ctx, cl, func(iv int) (interface{}, reflect.Kind, error) {
 						if iv == 0 {
 							return i, reflect.ValueOf(i).Kind(), nil
 						} else {
@@ -1287,7 +1679,7 @@ func (t *Transformation) Evaluate(ctx Context) (interface{}, reflect.Kind, error
 				Len:    func() int { return ts.Len() },
 				Source: ts.Transform,
 				Value: func(ctx Context, i, val interface{}) (interface{}, reflect.Kind, error) {
-					return t.Fn.internalExecute(ctx, 2, func(iv int) (interface{}, reflect.Kind, error) {
+					return t.apply(ctx, cl, func(iv int) (interface{}, reflect.Kind, error) {
 						if iv == 0 {
 							return i.(int64), reflect.Int64, nil
 						} else {
@@ -1303,7 +1695,7 @@ func (t *Transformation) Evaluate(ctx Context) (interface{}, reflect.Kind, error
 				Len:    func() int { return ts.Len() },
 				Source: ts.Transform,
 				Value: func(ctx Context, i, val interface{}) (interface{}, reflect.Kind, error) {
-					return t.Fn.internalExecute(ctx, 2, func(iv int) (interface{}, reflect.Kind, error) {
+					return t.apply(ctx, cl, func(iv int) (interface{}, reflect.Kind, error) {
 						if iv == 0 {
 							return i, reflect.ValueOf(i).Kind(), nil
 						} else {
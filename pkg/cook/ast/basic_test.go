@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/cozees/cook/pkg/cook/token"
 	"github.com/stretchr/testify/assert"
@@ -481,6 +482,39 @@ var exprCases = []*ExprAstTestCase{
 		value: false,
 		kind:  reflect.Bool,
 	},
+	{ // case 72
+		node: &Pipe{
+			X: &Call{Kind: token.HASH, Name: "sh", Args: []Node{&BasicLit{Lit: "-c", Kind: token.STRING}, &BasicLit{Lit: "yes | head -n 100000", Kind: token.STRING}}},
+			Y: &Pipe{
+				X: &Call{Kind: token.HASH, Name: "sort"},
+				Y: &Call{Kind: token.HASH, Name: "wc", Args: []Node{&BasicLit{Lit: "-l", Kind: token.STRING}}},
+			},
+			OutputResult: true,
+		},
+		value: "100000\n",
+		kind:  reflect.String,
+	},
+	{ // case 73
+		node: &Pipe{
+			X: &Call{Kind: token.AT, Name: "print", Args: []Node{echo, &BasicLit{Lit: "a.txt", Kind: token.STRING}}},
+			Y: &Pipe{
+				X: &Call{Kind: token.HASH, Name: "tr", Args: []Node{&BasicLit{Lit: "a-z", Kind: token.STRING}, &BasicLit{Lit: "A-Z", Kind: token.STRING}}},
+				Y: &Call{Kind: token.AT, Name: "pext"},
+			},
+			OutputResult: true,
+		},
+		value: ".TXT\n",
+		kind:  reflect.String,
+	},
+	{ // case 74, yes never exit by itself, the commands must run at the same time
+		node: &Pipe{
+			X:            &Call{Kind: token.HASH, Name: "yes"},
+			Y:            &Call{Kind: token.HASH, Name: "head", Args: []Node{&BasicLit{Lit: "-n", Kind: token.STRING}, &BasicLit{Lit: "2", Kind: token.STRING}}},
+			OutputResult: true,
+		},
+		value: "y\ny\n",
+		kind:  reflect.String,
+	},
 }
 
 func TestExpression(t *testing.T) {
@@ -506,6 +540,35 @@ func TestCallExpression(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, k, reflect.String)
 	assert.Equal(t, fmt.Sprintf("go version %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH), result)
+	// test command result, non-zero exit code is not an error
+	call = &Call{Kind: token.HASH, Name: "go", Args: []Node{&BasicLit{Lit: "vet", Kind: token.STRING}, &BasicLit{Lit: "nofile.go", Kind: token.STRING}}, OutputResult: true, Result: true}
+	result, k, err = call.Evaluate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, reflect.Map, k)
+	m := result.(map[interface{}]interface{})
+	assert.NotEqual(t, int64(0), m["code"])
+	assert.Equal(t, "", m["stdout"])
+	assert.Contains(t, m["stderr"], "nofile.go")
+	assert.Greater(t, m["duration"], float64(0))
+	call = &Call{Kind: token.HASH, Name: "nocommand", OutputResult: true, Result: true}
+	_, _, err = call.Evaluate(ctx)
+	assert.Error(t, err)
+	// test command option
+	call = &Call{Kind: token.HASH, Name: "sh", Args: []Node{&BasicLit{Lit: "-c", Kind: token.STRING}, &BasicLit{Lit: "pwd; printenv COOK_OPTION", Kind: token.STRING}}, OutputResult: true, Options: []*NamedArg{
+		{Name: "dir", X: &BasicLit{Lit: "/", Kind: token.STRING}},
+		{Name: "env", X: &MapLiteral{Keys: []Node{&BasicLit{Lit: "COOK_OPTION", Kind: token.STRING}}, Values: []Node{il1}}},
+	}}
+	result, _, err = call.Evaluate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "/\n12\n", result)
+	call = &Call{Kind: token.HASH, Name: "sleep", Args: []Node{&BasicLit{Lit: "5", Kind: token.STRING}}, Options: []*NamedArg{
+		{Name: "timeout", X: &BasicLit{Lit: "0.1", Kind: token.FLOAT}},
+	}}
+	start := time.Now()
+	_, _, err = call.Evaluate(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "command sleep timed out after 100ms")
+	assert.Less(t, time.Since(start), 5*time.Second)
 	// test target
 	icook := NewCook()
 	target, err := icook.AddTarget(dummyBase, "sample")
@@ -532,6 +595,44 @@ func TestCallExpression(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, reflect.String, k)
 	assert.Equal(t, sl2.Lit+"\n", result)
+	// test built-in function which receive an array as a single argument
+	for i, tc := range []struct {
+		args   []Node
+		output string
+	}{
+		{args: []Node{&ArrayLiteral{Values: []Node{il1}}}, output: "[12]"},                     // case 1
+		{args: []Node{&ArrayLiteral{}}, output: "[]"},                                          // case 2
+		{args: []Node{&ArrayLiteral{Values: []Node{il1, &ArrayLiteral{}}}}, output: "[12,[]]"}, // case 3
+		{args: []Node{il1, &ArrayLiteral{Values: []Node{il2}}}, output: "[12,[21]]"},           // case 4
+		{args: []Node{il1}, output: "12"},                                                      // case 5
+	} {
+		t.Logf("TestCallExpression jsonencode case #%d", i+1)
+		call = &Call{Kind: token.AT, Name: "jsonencode", Args: tc.args}
+		result, k, err = call.Evaluate(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, reflect.String, k)
+		assert.Equal(t, tc.output, result)
+	}
+	call = &Call{Kind: token.AT, Name: "query", Args: []Node{&BasicLit{Lit: ".[0]", Kind: token.STRING}, &ArrayLiteral{Values: []Node{il1}}}}
+	result, k, err = call.Evaluate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, reflect.Int64, k)
+	assert.Equal(t, int64(12), result)
+	tmpl := filepath.Join(t.TempDir(), "list.tmpl")
+	require.NoError(t, ioutil.WriteFile(tmpl, []byte("{{range .}}{{.}} {{end}}"), 0644))
+	for i, tc := range []struct {
+		data   Node
+		output string
+	}{
+		{data: &ArrayLiteral{Values: []Node{sl2}}, output: "sample "}, // case 1
+		{data: &ArrayLiteral{}, output: ""},                           // case 2
+	} {
+		t.Logf("TestCallExpression template case #%d", i+1)
+		call = &Call{Kind: token.AT, Name: "template", Args: []Node{&BasicLit{Lit: tmpl, Kind: token.STRING}, tc.data}}
+		result, _, err = call.Evaluate(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, tc.output, result)
+	}
 	// test function literal
 	idents := []*Ident{{Name: "a"}, {Name: "b"}}
 	call = &Call{
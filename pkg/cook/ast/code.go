@@ -47,6 +47,12 @@ type builder struct {
 	indentCount int
 	maxLength   int
 	formatter   bool
+
+	// fields below are only used by Format
+	file     *token.File
+	src      []byte
+	comments []token.Comment
+	limit    int
 }
 
 func NewCodeBuilder(indent string, formatter bool, maxLength int) CodeBuilder {
@@ -96,6 +102,7 @@ func (r *Interval) String() string             { return codeOf(r) }
 func (osc *OSysCheck) String() string          { return codeOf(osc) }
 func (e *Exists) String() string               { return codeOf(e) }
 func (c *Call) String() string                 { return codeOf(c) }
+func (na *NamedArg) String() string            { return codeOf(na) }
 func (pp *Pipe) String() string                { return codeOf(pp) }
 func (rf *ReadFrom) String() string            { return codeOf(rf) }
 func (rt *RedirectTo) String() string          { return codeOf(rt) }
@@ -104,6 +111,9 @@ func (un *Unary) String() string               { return codeOf(un) }
 func (idc *IncDec) String() string             { return codeOf(idc) }
 func (b *Binary) String() string               { return codeOf(b) }
 func (t *Transformation) String() string       { return codeOf(t) }
+func (fl *FunctionLit) String() string         { return codeOf(fl) }
+func (sp *Spawn) String() string               { return codeOf(sp) }
+func (w *Wait) String() string                 { return codeOf(w) }
 func (si *StringInterpolation) String() string { return codeOf(si) }
 
 func (bl *BasicLit) Visit(cb CodeBuilder) {
@@ -162,8 +172,12 @@ func (e *Exit) Visit(cb CodeBuilder) {
 }
 
 func (al *ArrayLiteral) Visit(cb CodeBuilder) {
+	multiline := al.Multiline
+	if b := formatterOf(cb); b != nil && !multiline && len(al.Values) > 0 {
+		multiline = b.column()+len(codeOf(al)) > b.maxLength
+	}
 	cb.WriteByte('[')
-	if al.Multiline {
+	if multiline {
 		cb.WriteByte('\n')
 		cb.IdentBy(1)
 		for _, val := range al.Values {
@@ -173,7 +187,7 @@ func (al *ArrayLiteral) Visit(cb CodeBuilder) {
 		}
 		cb.IdentBy(-1)
 		cb.WriteIndent()
-		cb.WriteString("]\n")
+		cb.WriteString("]")
 	} else {
 		for i, val := range al.Values {
 			if i > 0 {
@@ -186,8 +200,12 @@ func (al *ArrayLiteral) Visit(cb CodeBuilder) {
 }
 
 func (ml *MapLiteral) Visit(cb CodeBuilder) {
+	multiline := ml.Multiline
+	if b := formatterOf(cb); b != nil && !multiline && len(ml.Keys) > 0 {
+		multiline = b.column()+len(codeOf(ml)) > b.maxLength
+	}
 	cb.WriteByte('{')
-	if ml.Multiline {
+	if multiline {
 		cb.WriteByte('\n')
 		cb.IdentBy(1)
 		for i, key := range ml.Keys {
@@ -281,7 +299,14 @@ func (e *Exists) Visit(cb CodeBuilder) {
 
 func (c *Call) Visit(cb CodeBuilder) {
 	cb.WriteString(c.Kind.String())
+	if c.Result {
+		cb.WriteString(c.Kind.String())
+	}
 	cb.WriteString(c.Name)
+	for _, opt := range c.Options {
+		cb.WriteByte(' ')
+		opt.Visit(cb)
+	}
 	for _, arg := range c.Args {
 		cb.WriteByte(' ')
 		if arg == nil {
@@ -293,6 +318,12 @@ func (c *Call) Visit(cb CodeBuilder) {
 	}
 }
 
+func (na *NamedArg) Visit(cb CodeBuilder) {
+	cb.WriteString(na.Name)
+	cb.WriteByte('=')
+	na.X.Visit(cb)
+}
+
 func (pp *Pipe) Visit(cb CodeBuilder) {
 	pp.X.Visit(cb)
 	if pp.Y != nil {
@@ -339,7 +370,20 @@ func (idc *IncDec) Visit(cb CodeBuilder) {
 }
 
 func (b *Binary) Visit(cb CodeBuilder) {
-	if _, ok := b.L.(*Binary); ok {
+	// the formatter only add parentheses when the precedence of the operand require it
+	fb := formatterOf(cb)
+	needParen := func(x Node, right bool) bool {
+		if xb, ok := x.(*Binary); !ok {
+			return false
+		} else if fb == nil {
+			return true
+		} else if right {
+			return xb.Op.Precedence() <= b.Op.Precedence()
+		} else {
+			return xb.Op.Precedence() < b.Op.Precedence()
+		}
+	}
+	if needParen(b.L, false) {
 		cb.WriteByte('(')
 		b.L.Visit(cb)
 		cb.WriteByte(')')
@@ -349,7 +393,7 @@ func (b *Binary) Visit(cb CodeBuilder) {
 	cb.WriteByte(' ')
 	cb.WriteString(b.Op.String())
 	cb.WriteByte(' ')
-	if _, ok := b.R.(*Binary); ok {
+	if needParen(b.R, true) {
 		cb.WriteByte('(')
 		b.R.Visit(cb)
 		cb.WriteByte(')')
@@ -360,7 +404,28 @@ func (b *Binary) Visit(cb CodeBuilder) {
 
 func (t *Transformation) Visit(cb CodeBuilder) {
 	t.Ident.Visit(cb)
-	t.Fn.Visit(cb)
+	if t.Mapper != nil {
+		cb.WriteByte('(')
+		t.Mapper.Visit(cb)
+		cb.WriteByte(')')
+	} else {
+		t.Fn.Visit(cb)
+	}
+}
+
+func (fl *FunctionLit) Visit(cb CodeBuilder) { fl.Fn.Visit(cb) }
+
+func (sp *Spawn) Visit(cb CodeBuilder) {
+	cb.WriteString("spawn ")
+	sp.X.Visit(cb)
+}
+
+func (w *Wait) Visit(cb CodeBuilder) {
+	cb.WriteString("wait")
+	if w.X != nil {
+		cb.WriteByte(' ')
+		w.X.Visit(cb)
+	}
 }
 
 func (si *StringInterpolation) Visit(cb CodeBuilder) {
@@ -391,6 +456,10 @@ func (as *AssignStatement) String() string         { return codeOf(as) }
 func (bs *BlockStatement) String() string          { return codeOf(bs) }
 func (ews *ExprWrapperStatement) String() string   { return codeOf(ews) }
 func (rs *ReturnStatement) String() string         { return codeOf(rs) }
+func (ts *TryStatement) String() string            { return codeOf(ts) }
+func (rs *RaiseStatement) String() string          { return codeOf(rs) }
+func (es *ExportStatement) String() string         { return codeOf(es) }
+func (us *UnsetStatement) String() string          { return codeOf(us) }
 
 func (fst *ForStatement) Visit(cb CodeBuilder) {
 	cb.WriteString("for")
@@ -424,6 +493,39 @@ func (ifst *IfStatement) Visit(cb CodeBuilder) {
 	}
 }
 
+func (ts *TryStatement) Visit(cb CodeBuilder) {
+	cb.WriteString("try")
+	ts.Insts.Visit(cb)
+	cb.WriteString(" catch")
+	if ts.Err != nil {
+		cb.WriteByte(' ')
+		cb.WriteString(ts.Err.Name)
+	}
+	ts.Catch.Visit(cb)
+}
+
+func (rs *RaiseStatement) Visit(cb CodeBuilder) {
+	cb.WriteString("raise ")
+	rs.X.Visit(cb)
+}
+
+func (es *ExportStatement) Visit(cb CodeBuilder) {
+	cb.WriteString("export ")
+	es.Name.Visit(cb)
+	if es.X != nil {
+		cb.WriteString(" = ")
+		es.X.Visit(cb)
+	}
+}
+
+func (us *UnsetStatement) Visit(cb CodeBuilder) {
+	cb.WriteString("unset")
+	for _, id := range us.Names {
+		cb.WriteByte(' ')
+		id.Visit(cb)
+	}
+}
+
 func (efst *ElseStatement) Visit(cb CodeBuilder) {
 	cb.WriteString(" else")
 	if efst.IfStmt != nil {
@@ -435,6 +537,10 @@ func (efst *ElseStatement) Visit(cb CodeBuilder) {
 }
 
 func (bs *BlockStatement) Visit(cb CodeBuilder) {
+	if b := formatterOf(cb); b != nil {
+		b.formatBlock(bs)
+		return
+	}
 	if !bs.plain {
 		cb.WriteString(" {\n")
 	}
@@ -485,6 +591,9 @@ func (t *Target) String() string    { return codeOf(t) }
 func (fn *Function) String() string { return codeOf(fn) }
 
 func (c *cook) Visit(cb CodeBuilder) {
+	if c.trace {
+		cb.WriteString("trace\n\n")
+	}
 	c.Insts.Visit(cb)
 	// TODO: how to handle multiple file ??
 	for _, t := range c.initializeTargets {
@@ -509,31 +618,108 @@ func (c *cook) Visit(cb CodeBuilder) {
 }
 
 func (t *Target) Visit(cb CodeBuilder) {
-	if cb.Len() > 0 {
+	b := formatterOf(cb)
+	if b == nil && cb.Len() > 0 {
 		cb.WriteByte('\n')
 	}
 	cb.WriteString(t.name)
+	t.visitParams(cb)
 	if t.all {
 		cb.WriteString(": *\n")
 	} else {
-		cb.WriteString(":\n")
+		cb.WriteByte(':')
+		for _, dep := range t.deps {
+			cb.WriteByte(' ')
+			cb.WriteString(dep.Name)
+		}
+		if len(t.inputs) > 0 {
+			cb.WriteString(" <")
+			for _, x := range t.inputs {
+				cb.WriteByte(' ')
+				x.Visit(cb)
+			}
+		}
+		if len(t.outputs) > 0 {
+			cb.WriteString(" >")
+			for _, x := range t.outputs {
+				cb.WriteByte(' ')
+				x.Visit(cb)
+			}
+		}
+		if b != nil {
+			next := b.limit
+			if len(t.Insts.Stmts) > 0 {
+				next = statementOffset(t.Insts.Stmts[0], next)
+			}
+			b.writeTrailing(next)
+		}
+		cb.WriteByte('\n')
 		t.Insts.plain = true
 		t.Insts.Visit(cb)
 	}
 }
 
-func (fn *Function) Visit(cb CodeBuilder) {
-	if fn.Name != "" {
-		cb.WriteString(fn.Name)
+func (t *Target) visitParams(cb CodeBuilder) {
+	if len(t.Params) == 0 {
+		return
 	}
+	cb.WriteByte('(')
+	for i, p := range t.Params {
+		if i > 0 {
+			cb.WriteString(", ")
+		}
+		cb.WriteString(p.Name)
+		if p.Type != token.ILLEGAL {
+			cb.WriteString(": ")
+			cb.WriteString(p.Type.String())
+		}
+		if p.Default != nil {
+			cb.WriteString(" = ")
+			p.Default.Visit(cb)
+		}
+	}
+	cb.WriteByte(')')
+}
+
+// Signature return the target name along with its arguments as declared.
+func (t *Target) Signature() string {
+	cb := NewCodeBuilder("", false, 0)
+	cb.WriteString(t.name)
+	t.visitParams(cb)
+	return cb.String()
+}
+
+// Signature return the function name along with its arguments as declared.
+func (fn *Function) Signature() string {
+	cb := NewCodeBuilder("", false, 0)
+	cb.WriteString(fn.Name)
+	fn.visitArgs(cb)
+	return cb.String()
+}
+
+func (fn *Function) visitArgs(cb CodeBuilder) {
 	cb.WriteByte('(')
 	for i, arg := range fn.Args {
 		if i > 0 {
 			cb.WriteString(", ")
 		}
+		if fn.Variadic && i == len(fn.Args)-1 {
+			cb.WriteString(token.ELLIPSIS.String())
+		}
 		arg.Visit(cb)
+		if x := fn.defaultOf(i); x != nil {
+			cb.WriteString(" = ")
+			x.Visit(cb)
+		}
 	}
 	cb.WriteByte(')')
+}
+
+func (fn *Function) Visit(cb CodeBuilder) {
+	if fn.Name != "" {
+		cb.WriteString(fn.Name)
+	}
+	fn.visitArgs(cb)
 	if fn.Lambda == token.LAMBDA {
 		cb.WriteString(" => ")
 		fn.X.Visit(cb)
@@ -3,8 +3,13 @@ package ast
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
+	"sync"
+	"time"
+
+	"github.com/cozees/cook/pkg/cook/token"
 
 	"github.com/cozees/cook/pkg/runtime/function"
 )
@@ -27,10 +32,33 @@ type xScope struct {
 	hasChild     bool
 	returnResult *ivar
 	vars         map[string]*ivar
+	// isolated scope keep new variable to itself instead of declaring them globally
+	isolated bool
+	// mu is only set on the global scope while targets are executed in parallel
+	mu *sync.RWMutex
+}
+
+// owner return the scope where the variable is declared or nil if it does not exist
+func (xs *xScope) owner(name string) *xScope {
+	for s := xs; s != nil; s = s.parent {
+		if _, ok := s.lookup(name); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+func (xs *xScope) lookup(name string) (*ivar, bool) {
+	if xs.mu != nil {
+		xs.mu.RLock()
+		defer xs.mu.RUnlock()
+	}
+	iv, ok := xs.vars[name]
+	return iv, ok
 }
 
 func (xs *xScope) GetVariable(name string) (value interface{}, kind reflect.Kind, fromEnv bool) {
-	if iv, ok := xs.vars[name]; !ok {
+	if iv, ok := xs.lookup(name); !ok {
 		if xs.parent == nil {
 			goto tryEnv
 		}
@@ -38,37 +66,72 @@ func (xs *xScope) GetVariable(name string) (value interface{}, kind reflect.Kind
 			return
 		}
 	} else {
+		if xs.mu != nil {
+			xs.mu.RLock()
+			defer xs.mu.RUnlock()
+		}
 		value, kind = iv.value, iv.kind
 		return
 	}
 tryEnv:
-	if value = os.Getenv(name); value.(string) != "" {
-		kind, fromEnv = reflect.String, true
+	if env, ok := os.LookupEnv(name); ok {
+		return env, reflect.String, true
 	}
 	return nil, 0, false
 }
 
 func (xs *xScope) SetVariable(name string, value interface{}, kind reflect.Kind, bubble func(v interface{}, k reflect.Kind) error) bool {
 	switch kind {
-	case reflect.Int64, reflect.Float64, reflect.Bool, reflect.String, reflect.Slice, reflect.Map, TransformSlice, TransformMap:
+	case reflect.Int64, reflect.Float64, reflect.Bool, reflect.String, reflect.Slice, reflect.Map, reflect.Func, reflect.Ptr, TransformSlice, TransformMap:
 	default:
 		panic(fmt.Sprintf("cook internal error: variable '%s' value: %v has an invalid type %s", name, value, kind))
 	}
 
-	if iv, ok := xs.vars[name]; ok {
-		iv.value, iv.kind = value, kind
+	if iv, ok := xs.lookup(name); ok {
+		if xs.mu != nil {
+			xs.mu.Lock()
+			iv.value, iv.kind = value, kind
+			xs.mu.Unlock()
+		} else {
+			iv.value, iv.kind = value, kind
+		}
 		if iv.bubble != nil {
 			iv.bubble(value, kind)
 		}
 	} else if xs.hasChild {
 		return xs.parent != nil && xs.parent.SetVariable(name, value, kind, bubble)
+	} else if xs.isolated {
+		if owner := xs.parent.owner(name); owner != nil {
+			return owner.SetVariable(name, value, kind, bubble)
+		}
+		xs.vars[name] = &ivar{value: value, kind: kind, bubble: bubble}
 	} else if xs.parent == nil || !xs.parent.SetVariable(name, value, kind, bubble) {
 		// we here mean not variable is no exist anywhere
+		if xs.mu != nil {
+			xs.mu.Lock()
+			defer xs.mu.Unlock()
+		}
 		xs.vars[name] = &ivar{value: value, kind: kind, bubble: bubble}
 	}
 	return true
 }
 
+// merge add every variable of from to the scope, a variable with the same name is replaced.
+func (xs *xScope) merge(from *xScope) {
+	if xs.mu != nil {
+		xs.mu.Lock()
+		defer xs.mu.Unlock()
+	}
+	for name, iv := range from.vars {
+		xs.vars[name] = iv
+	}
+}
+
+// declare add the variable to the scope even if a variable with the same name exist in its parent.
+func (xs *xScope) declare(name string, value interface{}, kind reflect.Kind) {
+	xs.vars[name] = &ivar{value: value, kind: kind}
+}
+
 func (xs *xScope) SetReturnValue(v interface{}, kind reflect.Kind) {
 	xs.returnResult = &ivar{value: v, kind: kind}
 }
@@ -85,6 +148,11 @@ type Context interface {
 	Scope
 	EnterBlock(forLoop bool, loopLabel string) (Scope, int)
 	ExitBlock(index int)
+	// CurrentScope return the scope of the block being executed
+	CurrentScope() Scope
+	// EnterScope enter a new block whose parent is the given scope instead of the current block, the
+	// returned function restore the current block. It is used to execute a closure.
+	EnterScope(parent Scope) (Scope, func())
 	ShouldBreak(fromLoop bool) bool
 	ResetBreakContinue()
 	Break(label string) error
@@ -92,16 +160,26 @@ type Context interface {
 	GetCommand(name string) function.Function
 	GetTarget(name string) *Target
 	GetFunction(name string) *Function
+	Stdout() io.Writer
+	Stderr() io.Writer
+	Options() *Options
+	Tracing() bool
+	Trace(pos token.Position, desc string)
 }
 
 type xContext struct {
-	scope *xScope
-	cook  *cook
+	scope  *xScope
+	cook   *cook
+	stdout io.Writer
+	stderr io.Writer
 	// for loop properties for break & continue
 	loopsLabel []string
 	continueAt int
 	breakAt    int
 	loops      []int
+	// job is the background job executed by the context if any and jobs are the jobs it started
+	job  *Job
+	jobs []*Job
 }
 
 func (xc *xContext) GetVariable(name string) (value interface{}, kind reflect.Kind, fromEnv bool) {
@@ -122,12 +200,17 @@ func (xc *xContext) GetReturnValue() (v interface{}, kind reflect.Kind) {
 
 func (xc *xContext) GetFunction(name string) *Function        { return xc.cook.fns[name] }
 func (xc *xContext) GetCommand(name string) function.Function { return function.GetFunction(name) }
-func (xc *xContext) GetTarget(name string) *Target {
-	if ind, ok := xc.cook.targets[name]; ok && len(xc.cook.targetIndexes) > 0 {
-		return xc.cook.targetIndexes[ind]
-	} else {
-		return nil
-	}
+func (xc *xContext) GetTarget(name string) *Target            { return xc.cook.getTarget(name) }
+func (xc *xContext) Stdout() io.Writer                        { return xc.stdout }
+func (xc *xContext) Stderr() io.Writer                        { return xc.stderr }
+func (xc *xContext) Options() *Options                        { return xc.cook.opts }
+func (xc *xContext) Tracing() bool                            { return xc.cook.opts.Trace || xc.cook.trace }
+
+// Trace write desc to the standard error along with the time elapsed since the execution started and
+// the position of the statement in Cookfile.
+func (xc *xContext) Trace(pos token.Position, desc string) {
+	elapsed := time.Since(xc.cook.start).Round(time.Microsecond)
+	fmt.Fprintf(xc.stderr, "+ [%s] %s %s\n", elapsed, pos, desc)
 }
 
 func (xc *xContext) EnterBlock(forLoop bool, loopLabel string) (Scope, int) {
@@ -141,6 +224,14 @@ func (xc *xContext) EnterBlock(forLoop bool, loopLabel string) (Scope, int) {
 	return xc.scope, loopIndex
 }
 
+func (xc *xContext) CurrentScope() Scope { return xc.scope }
+
+func (xc *xContext) EnterScope(parent Scope) (Scope, func()) {
+	current := xc.scope
+	xc.scope = &xScope{parent: parent.(*xScope), vars: make(map[string]*ivar)}
+	return xc.scope, func() { xc.scope = current }
+}
+
 func (xc *xContext) ExitBlock(index int) {
 	if index >= 0 {
 		if index != len(xc.loopsLabel)-1 {
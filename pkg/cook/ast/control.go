@@ -1,8 +1,13 @@
 package ast
 
 import (
+	"errors"
 	"fmt"
+	"os/exec"
 	"reflect"
+
+	"github.com/cozees/cook/pkg/cook/token"
+	cookErrors "github.com/cozees/cook/pkg/errors"
 )
 
 //
@@ -161,14 +166,14 @@ func (fst *ForStatement) executeInsts(ctx Context, ikind reflect.Kind, vkind ref
 	if ikind != reflect.Invalid {
 		v, k, _ := ctx.GetVariable(fst.I.Name)
 		if ikind != k {
-			return false, 0, nil, fmt.Errorf("%s: for loop index type %s cannot be modfied to type %s", fst.I.ErrPos(), ikind, k)
+			return false, 0, nil, cookErrors.Errorf(fst.I.Position(), cookErrors.CodeType, "for loop index type %s cannot be modfied to type %s", ikind, k)
 		}
 		return sb, v, nil, nil
 	}
 	if vkind != reflect.Invalid {
 		v, k, _ := ctx.GetVariable(fst.Value.Name)
 		if vkind != k {
-			return false, 0, nil, fmt.Errorf("%s: for loop value type %s cannot be modfied to type %s", fst.I.ErrPos(), vkind, k)
+			return false, 0, nil, cookErrors.Errorf(fst.I.Position(), cookErrors.CodeType, "for loop value type %s cannot be modfied to type %s", vkind, k)
 		}
 		return sb, -1, v, nil
 	}
@@ -210,3 +215,89 @@ func (efst *ElseStatement) Evaluate(ctx Context) error {
 		return efst.Insts.Evaluate(ctx)
 	}
 }
+
+// TryStatement execute the statements of Insts, an error returned by any of them stop the block and
+// the statements of Catch are executed instead with the error value assigned to Err if it is given.
+type TryStatement struct {
+	*Base
+	Insts *BlockStatement
+	Err   *Ident
+	Catch *BlockStatement
+}
+
+func (ts *TryStatement) Evaluate(ctx Context) error {
+	err := ts.Insts.Evaluate(ctx)
+	if err == nil {
+		return nil
+	}
+	if ts.Err != nil {
+		ctx.SetVariable(ts.Err.Name, ErrorValue(err), reflect.Map, nil)
+	}
+	return ts.Catch.Evaluate(ctx)
+}
+
+// RaiseStatement return an error from the value of X, X is either a message or an error value
+// which is caught by a try statement.
+type RaiseStatement struct {
+	*Base
+	X Node
+}
+
+// raisedError is the error returned by raise statement.
+type raisedError struct {
+	msg  string
+	exit int64
+}
+
+func (re *raisedError) Error() string { return re.msg }
+
+func (rs *RaiseStatement) Evaluate(ctx Context) error {
+	v, k, err := rs.X.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	code, re := cookErrors.CodeRaise, &raisedError{exit: 1}
+	if m, ok := v.(map[interface{}]interface{}); ok {
+		// raise an error value again, its message, code and exit code are kept
+		if msg, ok := m["message"].(string); ok {
+			re.msg = msg
+		}
+		if c, ok := m["code"].(string); ok && c != "" {
+			code = cookErrors.Code(c)
+		}
+		if exit, ok := m["exit"].(int64); ok {
+			re.exit = exit
+		}
+	} else if re.msg, err = convertToString(ctx, v, k); err != nil {
+		return cookErrors.NewDiagnostic(rs.X.Position(), cookErrors.CodeType, err)
+	}
+	return cookErrors.NewDiagnostic(rs.Position(), code, re)
+}
+
+// ErrorValue return the error as a map which can be read by the Cookfile. The map contain the
+// message, the code, the position where the error occurred as well as its file and line, and the
+// exit code which is the exit code of the command if the error is returned by a command.
+func ErrorValue(err error) map[interface{}]interface{} {
+	d := cookErrors.NewDiagnostic(token.Position{}, cookErrors.CodeRuntime, err)
+	return map[interface{}]interface{}{
+		"message":  d.Message,
+		"code":     string(d.Code),
+		"position": d.Position.String(),
+		"file":     d.Position.Filename,
+		"line":     int64(d.Position.Line),
+		"exit":     int64(ExitCode(err)),
+	}
+}
+
+// ExitCode return the process exit code that cook should use when err is not caught.
+// It is the exit code of a failed command, the exit given to raise or 1 otherwise.
+func ExitCode(err error) int {
+	var ee *exec.ExitError
+	var re *raisedError
+	if errors.As(err, &ee) {
+		return ee.ExitCode()
+	} else if errors.As(err, &re) {
+		return int(re.exit)
+	}
+	return 1
+}
@@ -4,11 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cozees/cook/pkg/cook/token"
+	cookErrors "github.com/cozees/cook/pkg/errors"
 	"github.com/cozees/cook/pkg/runtime/args"
 )
 
@@ -25,11 +30,46 @@ type Cook interface {
 	AddTarget(base *Base, name string) (*Target, error)
 	Execute(pargs map[string]interface{}) error
 	ExecuteWithTarget(pargs map[string]interface{}, names ...string) error
+	SetOptions(opts *Options)
+	EnableTrace()
+	// AddInclude record the path of a file included by the Cookfile
+	AddInclude(file string)
+	// Includes return the path of every file included by the Cookfile or by another included file
+	Includes() []string
 	Scope() Scope
+	// Targets return every target which can be requested by name in the order of its declaration
+	// including target all if it was declared.
+	Targets() []*Target
+	// Functions return every function declared in Cookfile ordered by its position.
+	Functions() []*Function
+}
+
+// Options control how targets are executed
+type Options struct {
+	// Force execute targets even if their outputs are up to date
+	Force bool
+	// Jobs is the maximum number of targets executed at the same time
+	Jobs int
+	// DryRun print external commands, built-in function calls and redirects instead of executing them
+	DryRun bool
+	// Dir is the directory of the main Cookfile where the build state is recorded, the working
+	// directory is used if it is empty
+	Dir string
+	// Trace log every statement, call, target and function to standard error before executing it
+	Trace bool
+	// TargetArgs is the arguments given by name to each requested target from the command line,
+	// e.g. cook deploy env=prod
+	TargetArgs map[string]map[string]string
 }
 
 type cook struct {
-	ctx *xContext
+	ctx  *xContext
+	opts *Options
+	// trace is set by the trace directive in any Cookfile
+	trace bool
+	// includes is the path of the included files in the order they are included
+	includes []string
+	start    time.Time
 
 	targets       map[string]int
 	targetIndexes []*Target
@@ -39,16 +79,29 @@ type cook struct {
 	finalizeTargets   Targets
 	targetAll         *Target
 	Insts             *BlockStatement
+
+	// numJobs is the number of background jobs started so far, it is used to identify a job
+	numJobs int32
+
+	// exports are the name of the variables exported to the environment of the commands
+	envMu   sync.Mutex
+	exports map[string]bool
 }
 
 func NewCook() Cook {
 	return &cook{
 		targets: make(map[string]int),
 		fns:     make(map[string]*Function),
+		opts:    &Options{},
 		Insts:   &BlockStatement{root: true, plain: true},
 	}
 }
 
+func (c *cook) SetOptions(opts *Options) { c.opts = opts }
+func (c *cook) EnableTrace()             { c.trace = true }
+func (c *cook) AddInclude(file string)   { c.includes = append(c.includes, file) }
+func (c *cook) Includes() []string       { return c.includes }
+
 func (c *cook) Block() *BlockStatement { return c.Insts }
 func (c *cook) Scope() Scope           { return c.ctx.scope }
 
@@ -90,6 +143,29 @@ func (c *cook) AddTarget(base *Base, name string) (*Target, error) {
 
 func (c *cook) AddFunction(fn *Function) { c.fns[fn.Name] = fn }
 
+func (c *cook) Targets() []*Target {
+	targets := make([]*Target, 0, len(c.targetIndexes)+1)
+	if c.targetAll != nil {
+		targets = append(targets, c.targetAll)
+	}
+	return append(targets, c.targetIndexes...)
+}
+
+func (c *cook) Functions() []*Function {
+	fns := make([]*Function, 0, len(c.fns))
+	for _, fn := range c.fns {
+		fns = append(fns, fn)
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		pi, pj := fns[i].position(), fns[j].position()
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return fns
+}
+
 func (c *cook) Execute(pargs map[string]interface{}) error {
 	if c.targetAll == nil {
 		return errors.New("default target all is not defined")
@@ -98,7 +174,26 @@ func (c *cook) Execute(pargs map[string]interface{}) error {
 }
 
 func (c *cook) ExecuteWithTarget(pargs map[string]interface{}, names ...string) (err error) {
+	// resolve every target and its dependencies before executing anything, so a missing target
+	// or a dependency cycle does not leave the work half done.
+	targets, err := c.requestedTargets(names)
+	if err != nil {
+		return err
+	} else if targets, err = c.resolveTargets(targets); err != nil {
+		return err
+	}
+	for name, targs := range c.opts.TargetArgs {
+		t := c.getTarget(name)
+		for arg := range targs {
+			if t == nil || t.paramIndex(arg) == -1 {
+				return fmt.Errorf("target %s does not have argument %s", name, arg)
+			}
+		}
+	}
 	c.ctx = c.renewContext()
+	c.start = time.Now()
+	// jobs spawned outside any target are killed after finalize targets
+	defer c.ctx.killJobs(0)
 	for name, v := range pargs {
 		c.ctx.scope.SetVariable(name, v, reflect.ValueOf(v).Kind(), nil)
 	}
@@ -125,38 +220,137 @@ func (c *cook) ExecuteWithTarget(pargs map[string]interface{}, names ...string)
 		}
 	}()
 
-	// if all target is need and using syntax "all: *" then we execute every target in the order
-	// of its declaration
-	if len(names) == 1 && names[0] == TargetAll {
-		c.ctx.EnterBlock(false, "")
-		if c.targetAll == nil {
-			return errors.New("target all is not defined in any Cookfile")
+	// each target must execute with it's own scope
+	state := loadBuildState(filepath.Join(c.opts.Dir, stateFile))
+	defer func() {
+		if serr := state.save(); serr != nil {
+			fmt.Fprintf(os.Stderr, "warning: unable to save build state: %s\n", serr)
 		}
-		if c.targetAll.all {
-			for _, t := range c.targetIndexes {
-				c.ctx.EnterBlock(false, "")
-				if err = t.Execute(c.ctx, nil); err != nil {
-					return err
-				}
-				c.ctx.ExitBlock(-1)
-			}
-		} else if err = c.targetAll.Execute(c.ctx, nil); err != nil {
+	}()
+	if c.opts.Jobs > 1 && len(targets) > 1 {
+		return c.executeParallel(targets, state)
+	}
+	for _, t := range targets {
+		c.ctx.EnterBlock(false, "")
+		if err = c.executeTarget(c.ctx, t, state); err != nil {
 			return err
 		}
 		c.ctx.ExitBlock(-1)
-	} else {
-		// each target must execute with it's own scope
-		for _, name := range names {
-			if name == TargetAll {
-				fmt.Println("warning: target all was include among other, it won't be executed.")
-				continue
-			}
-			c.ctx.EnterBlock(false, "")
-			if err = c.ctx.GetTarget(name).Execute(c.ctx, nil); err != nil {
+	}
+	return nil
+}
+
+// executeTarget execute the target unless its outputs are up to date with its inputs.
+func (c *cook) executeTarget(ctx Context, t *Target, state *buildState) error {
+	isUpToDate, inputs, err := t.upToDate(ctx, state)
+	if err != nil {
+		return err
+	} else if isUpToDate && !c.opts.Force {
+		fmt.Fprintf(ctx.Stdout(), "target %s is up to date\n", t.name)
+		return nil
+	}
+	if c.opts.DryRun {
+		fmt.Fprintf(ctx.Stdout(), "%s:\n", t.name)
+	}
+	if err = t.Execute(ctx, c.targetArgs(t.name)); err != nil {
+		return err
+	}
+	if len(t.outputs) > 0 && !c.opts.DryRun {
+		if hashes, err := hashFiles(inputs); err == nil {
+			state.record(t.name, hashes)
+		}
+	}
+	return nil
+}
+
+// requestedTargets return the target to be executed for the given names. If all target is need
+// and using syntax "all: *" then every target is return in the order of its declaration.
+func (c *cook) requestedTargets(names []string) ([]*Target, error) {
+	if len(names) == 1 && names[0] == TargetAll {
+		if c.targetAll == nil {
+			return nil, errors.New("target all is not defined in any Cookfile")
+		} else if c.targetAll.all {
+			return c.targetIndexes, nil
+		}
+		return []*Target{c.targetAll}, nil
+	}
+	targets := make([]*Target, 0, len(names))
+	for _, name := range names {
+		if name == TargetAll {
+			fmt.Println("warning: target all was include among other, it won't be executed.")
+			continue
+		}
+		if t := c.getTarget(name); t != nil {
+			targets = append(targets, t)
+		} else {
+			return nil, fmt.Errorf("target %s is not defined in any Cookfile", name)
+		}
+	}
+	return targets, nil
+}
+
+// resolveTargets return the given targets along with their dependencies in the order they must be
+// executed. A target that is required by several others appear only once in the result.
+func (c *cook) resolveTargets(targets []*Target) ([]*Target, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*Target]int)
+	order := make([]*Target, 0, len(targets))
+	var visit func(t *Target, path []*Target) error
+	visit = func(t *Target, path []*Target) error {
+		if state[t] == visited {
+			return nil
+		}
+		state[t] = visiting
+		path = append(path, t)
+		for _, dep := range t.deps {
+			dt := c.getTarget(dep.Name)
+			if dt == nil {
+				return cookErrors.Errorf(dep.Position(), cookErrors.CodeUndefined, "target %s depends on undefined target %s", t.name, dep.Name)
+			} else if state[dt] == visiting {
+				cycle := dt.name
+				for i := len(path) - 1; path[i] != dt; i-- {
+					cycle = path[i].name + " -> " + cycle
+				}
+				return cookErrors.Errorf(dep.Position(), cookErrors.CodeDependency,
+					"dependency cycle %s -> %s, target %s declared at %s depends on target %s declared at %s",
+					dt.name, cycle, t.name, t.ErrPos(), dt.name, dt.ErrPos())
+			} else if err := visit(dt, path); err != nil {
 				return err
 			}
-			c.ctx.ExitBlock(-1)
 		}
+		state[t] = visited
+		order = append(order, t)
+		return nil
+	}
+	for _, t := range targets {
+		if err := visit(t, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// targetArgs return the arguments given to the target from the command line ordered by name.
+func (c *cook) targetArgs(name string) []*args.FunctionArg {
+	targs := c.opts.TargetArgs[name]
+	names := make([]string, 0, len(targs))
+	for arg := range targs {
+		names = append(names, arg)
+	}
+	sort.Strings(names)
+	fargs := make([]*args.FunctionArg, len(names))
+	for i, arg := range names {
+		fargs[i] = &args.FunctionArg{Name: arg, Val: targs[arg], Kind: reflect.String}
+	}
+	return fargs
+}
+
+func (c *cook) getTarget(name string) *Target {
+	if ind, ok := c.targets[name]; ok && len(c.targetIndexes) > 0 {
+		return c.targetIndexes[ind]
 	}
 	return nil
 }
@@ -165,6 +359,8 @@ func (c *cook) renewContext() *xContext {
 	return &xContext{
 		scope:      &xScope{vars: make(map[string]*ivar)},
 		cook:       c,
+		stdout:     os.Stdout,
+		stderr:     os.Stderr,
 		continueAt: -1,
 		breakAt:    -1,
 	}
@@ -188,11 +384,135 @@ func (t Targets) notExisted(fname string) error {
 	return nil
 }
 
+// Param is an argument declared by a target, e.g. deploy(env: string, replicas: integer = 1):. Its
+// Type is token.ILLEGAL if any type is accepted and its Default is nil if the argument is required.
+type Param struct {
+	*Ident
+	Type    token.Token
+	Default Node
+}
+
+// convert return the value as the declared type, a string is parsed to the type as it is how the
+// value is given from the command line.
+func (p *Param) convert(v interface{}, k reflect.Kind) (interface{}, reflect.Kind, error) {
+	want := p.Type.Kind()
+	if want == reflect.Invalid || want == k {
+		return v, k, nil
+	}
+	s, isString := v.(string)
+	switch {
+	case want == reflect.Float64 && k == reflect.Int64:
+		return float64(v.(int64)), want, nil
+	case isString && want == reflect.Int64:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, want, nil
+		}
+	case isString && want == reflect.Float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, want, nil
+		}
+	case isString && want == reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, want, nil
+		}
+	}
+	return nil, 0, cookErrors.Errorf(p.Position(), cookErrors.CodeType, "argument %s require %s, given %s", p.Name, p.Type, FormatValue(v))
+}
+
 type Target struct {
 	*Base
-	all   bool
-	Insts *BlockStatement
-	name  string
+	all     bool
+	deps    []*Ident
+	inputs  []Node
+	outputs []Node
+	Insts   *BlockStatement
+	name    string
+	// Params is the arguments declared by the target, if there is none the arguments are only
+	// available by their position, e.g. $1
+	Params []*Param
+	// Doc is the comment placed directly above the target
+	Doc string
+}
+
+func (t *Target) Name() string { return t.name }
+
+// Dependencies return name of the targets which must be executed before this target.
+func (t *Target) Dependencies() []string {
+	names := make([]string, len(t.deps))
+	for i, dep := range t.deps {
+		names[i] = dep.Name
+	}
+	return names
+}
+
+// AddDependency register a target that must be executed before this target. Dependencies are
+// executed only once per execution no matter how many target depend on them.
+func (t *Target) AddDependency(base *Base, name string) error {
+	switch t.name {
+	case TargetInitialize, TargetFinalize:
+		return fmt.Errorf("%s target cannot depend on other target", t.name)
+	}
+	switch name {
+	case TargetInitialize, TargetFinalize, TargetAll:
+		return fmt.Errorf("target %s cannot be used as a dependency", name)
+	}
+	for _, dep := range t.deps {
+		if dep.Name == name {
+			return fmt.Errorf("target %s is already a dependency of target %s", name, t.name)
+		}
+	}
+	t.deps = append(t.deps, &Ident{Base: base, Name: name})
+	return nil
+}
+
+// AddInput register a file, array of file or glob pattern which the target read from. Inputs are
+// only considered when the target also declare its outputs.
+func (t *Target) AddInput(x Node) error {
+	if err := t.canTrackFile(); err != nil {
+		return err
+	}
+	t.inputs = append(t.inputs, x)
+	return nil
+}
+
+// AddOutput register a file or array of file which the target produce. A target which declare its
+// outputs is skipped when the outputs are up to date with its inputs.
+func (t *Target) AddOutput(x Node) error {
+	if err := t.canTrackFile(); err != nil {
+		return err
+	}
+	t.outputs = append(t.outputs, x)
+	return nil
+}
+
+// AddParam declare an argument of the target which can be given by its position or by its name.
+func (t *Target) AddParam(p *Param) error {
+	switch t.name {
+	case TargetInitialize, TargetFinalize, TargetAll:
+		return fmt.Errorf("%s target cannot declare argument", t.name)
+	}
+	if t.paramIndex(p.Name) != -1 {
+		return fmt.Errorf("argument %s is already declared by target %s", p.Name, t.name)
+	}
+	t.Params = append(t.Params, p)
+	return nil
+}
+
+func (t *Target) paramIndex(name string) int {
+	for i, p := range t.Params {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *Target) canTrackFile() error {
+	switch t.name {
+	case TargetInitialize, TargetFinalize, TargetAll:
+		return fmt.Errorf("%s target cannot declare input or output file", t.name)
+	}
+	return nil
 }
 
 func (t *Target) SetCallAll() {
@@ -202,14 +522,108 @@ func (t *Target) SetCallAll() {
 	t.all = true
 }
 
-func (t *Target) Execute(ctx Context, args []*args.FunctionArg) error {
+func (t *Target) Execute(ctx Context, fargs []*args.FunctionArg) error {
+	if ctx.Tracing() {
+		ctx.Trace(t.Position(), "target "+strings.TrimSpace(t.name+" "+formatArgs(fargs, " ")))
+	}
 	scope, _ := ctx.EnterBlock(false, "")
 	defer ctx.ExitBlock(-1)
-	for i, fa := range args {
+	if xc, ok := ctx.(*xContext); ok {
+		// background jobs started by the target must not outlive it
+		defer xc.killJobs(len(xc.jobs))
+	}
+	var positional, named []*args.FunctionArg
+	for _, fa := range fargs {
+		if fa.Name != "" {
+			named = append(named, fa)
+		} else {
+			positional = append(positional, fa)
+		}
+	}
+	// positional arguments are always available by their index
+	for i, fa := range positional {
 		scope.SetVariable(strconv.Itoa(i+1), fa.Val, fa.Kind, nil)
 	}
-	scope.SetVariable("0", int64(len(args)), reflect.Int64, nil)
-	return t.Insts.Evaluate(ctx)
+	scope.SetVariable("0", int64(len(positional)), reflect.Int64, nil)
+	err := t.bindParams(ctx, scope, positional, named)
+	if err == nil {
+		err = t.Insts.Evaluate(ctx)
+	}
+	if err != nil {
+		d := cookErrors.NewDiagnostic(t.Position(), cookErrors.CodeRuntime, err)
+		d.PushFrame("target", t.name, t.Position())
+		return d
+	}
+	return nil
+}
+
+// CheckArgs verify that the positional arguments and the named arguments can be given to the
+// target. A target which does not declare any argument accept positional arguments only.
+func (t *Target) CheckArgs(numArgs int, names []string) error {
+	if len(t.Params) == 0 {
+		if len(names) > 0 {
+			return fmt.Errorf("target %s does not have argument %s", t.name, names[0])
+		}
+		return nil
+	} else if numArgs > len(t.Params) {
+		return fmt.Errorf("too many argument defined %d, given %d", len(t.Params), numArgs)
+	}
+	given := make([]bool, len(t.Params))
+	for i := 0; i < numArgs; i++ {
+		given[i] = true
+	}
+	for _, name := range names {
+		i := t.paramIndex(name)
+		if i == -1 {
+			return fmt.Errorf("target %s does not have argument %s", t.name, name)
+		} else if given[i] {
+			return fmt.Errorf("argument %s is given more than once", name)
+		}
+		given[i] = true
+	}
+	for i, ok := range given {
+		if !ok && t.Params[i].Default == nil {
+			return fmt.Errorf("target %s require argument %s", t.name, t.Params[i].Name)
+		}
+	}
+	return nil
+}
+
+// bindParams set the declared arguments from the positional and the named arguments, an argument
+// which is not given is set to its default value. A target which does not declare any argument
+// accept any number of positional arguments.
+func (t *Target) bindParams(ctx Context, scope Scope, positional, named []*args.FunctionArg) error {
+	names := make([]string, len(named))
+	for i, fa := range named {
+		names[i] = fa.Name
+	}
+	if err := t.CheckArgs(len(positional), names); err != nil {
+		return cookErrors.NewDiagnostic(token.Position{}, cookErrors.CodeArgument, err)
+	}
+	values := make([]*args.FunctionArg, len(t.Params))
+	copy(values, positional)
+	for _, fa := range named {
+		values[t.paramIndex(fa.Name)] = fa
+	}
+	for i, p := range t.Params {
+		var v interface{}
+		var k reflect.Kind
+		var err error
+		switch {
+		case values[i] != nil:
+			v, k = values[i].Val, values[i].Kind
+		default:
+			// default value is evaluated in the target scope thus it can refer to the preceding arguments
+			if v, k, err = p.Default.Evaluate(ctx); err != nil {
+				return cookErrors.NewDiagnostic(p.Default.Position(), cookErrors.CodeRuntime, err)
+			}
+		}
+		if v, k, err = p.convert(v, k); err != nil {
+			return err
+		}
+		scope.SetVariable(p.Name, v, k, nil)
+	}
+	return nil
 }
 
 func (t *Target) Vist(cb CodeBuilder) {
@@ -222,38 +636,181 @@ func (t *Target) Vist(cb CodeBuilder) {
 type argumentSetter func(int) (interface{}, reflect.Kind, error)
 
 type Function struct {
+	// Base is only available for function declared in Cookfile
+	*Base
 	Insts  *BlockStatement
 	Name   string
 	Lambda token.Token
 	X      Node
 	Args   []*Ident
+	// Defaults is the default value of each argument, nil if the argument does not have one
+	Defaults []Node
+	// Variadic is true when the last argument receive the remaining arguments as an array
+	Variadic bool
+	// Doc is the comment placed directly above the function declaration
+	Doc string
+}
+
+func (fn *Function) position() token.Position {
+	if fn.Base != nil {
+		return fn.Base.Position()
+	} else if fn.Lambda == token.LAMBDA {
+		return fn.X.Position()
+	}
+	return fn.Insts.Position()
+}
+
+// fixedArgs return the number of arguments which is not variadic.
+func (fn *Function) fixedArgs() int {
+	if fn.Variadic {
+		return len(fn.Args) - 1
+	}
+	return len(fn.Args)
+}
+
+func (fn *Function) defaultOf(i int) Node {
+	if i < len(fn.Defaults) {
+		return fn.Defaults[i]
+	}
+	return nil
+}
+
+func (fn *Function) argIndex(name string) int {
+	for i, arg := range fn.Args[:fn.fixedArgs()] {
+		if arg.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// CheckArgs verify that the positional arguments and the named arguments can be given to the
+// function, every argument which does not have a default value must be given exactly once.
+func (fn *Function) CheckArgs(numArgs int, names []string) error {
+	fixed := fn.fixedArgs()
+	if numArgs > fixed && !fn.Variadic {
+		return fmt.Errorf("too many argument defined %d, given %d", fixed, numArgs)
+	}
+	given := make([]bool, fixed)
+	for i := 0; i < numArgs && i < fixed; i++ {
+		given[i] = true
+	}
+	for _, name := range names {
+		i := fn.argIndex(name)
+		if i == -1 {
+			return fmt.Errorf("unknown argument %s", name)
+		} else if given[i] {
+			return fmt.Errorf("argument %s is given more than once", name)
+		}
+		given[i] = true
+	}
+	for i, ok := range given {
+		if !ok && fn.defaultOf(i) == nil {
+			return fmt.Errorf("missing argument %s", fn.Args[i].Name)
+		}
+	}
+	return nil
 }
 
 func (fn *Function) Execute(ctx Context, pargs []Node) (v interface{}, kind reflect.Kind, err error) {
-	return fn.internalExecute(ctx, len(pargs), func(i int) (interface{}, reflect.Kind, error) {
-		return pargs[i].Evaluate(ctx)
+	return fn.execute(ctx, nil, pargs)
+}
+
+func (fn *Function) execute(ctx Context, parent Scope, pargs []Node) (v interface{}, kind reflect.Kind, err error) {
+	var positional []Node
+	var named []*NamedArg
+	for _, arg := range pargs {
+		if na, ok := arg.(*NamedArg); ok {
+			named = append(named, na)
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+	return fn.internalExecute(ctx, parent, len(positional), named, func(i int) (interface{}, reflect.Kind, error) {
+		return positional[i].Evaluate(ctx)
 	})
 }
 
-func (fn *Function) internalExecute(ctx Context, numArgs int, farg argumentSetter) (v interface{}, kind reflect.Kind, err error) {
-	switch {
-	case numArgs > len(fn.Args):
-		return nil, 0, fmt.Errorf("too many argument defined %d, given %d", len(fn.Args), numArgs)
-	case numArgs < len(fn.Args):
-		return nil, 0, fmt.Errorf("not enought argument defined %d, given %d", len(fn.Args), numArgs)
+type argValue struct {
+	v   interface{}
+	k   reflect.Kind
+	set bool
+}
+
+// internalExecute execute the function in a new block, the block is a child of parent if it is not
+// nil, otherwise it is a child of the current block.
+func (fn *Function) internalExecute(ctx Context, parent Scope, numArgs int, named []*NamedArg, farg argumentSetter) (v interface{}, kind reflect.Kind, err error) {
+	names := make([]string, len(named))
+	for i, na := range named {
+		names[i] = na.Name
+	}
+	if err = fn.CheckArgs(numArgs, names); err != nil {
+		return nil, 0, cookErrors.NewDiagnostic(token.Position{}, cookErrors.CodeArgument, err)
 	}
 
-	scope, _ := ctx.EnterBlock(false, "")
-	defer ctx.ExitBlock(-1)
+	// arguments are evaluated before entering the function scope so an argument is never shadowed
+	// by the function argument which has the same name.
+	fixed := fn.fixedArgs()
+	values := make([]argValue, fixed)
+	var rest []interface{}
 	for i := 0; i < numArgs; i++ {
 		if v, k, err := farg(i); err != nil {
 			return nil, 0, err
+		} else if i < fixed {
+			values[i] = argValue{v, k, true}
 		} else {
-			scope.SetVariable(fn.Args[i].Name, v, k, nil)
+			rest = append(rest, v)
 		}
 	}
+	for _, na := range named {
+		if v, k, err := na.X.Evaluate(ctx); err != nil {
+			return nil, 0, err
+		} else {
+			values[fn.argIndex(na.Name)] = argValue{v, k, true}
+		}
+	}
+
+	var scope Scope
+	if parent != nil {
+		var restore func()
+		scope, restore = ctx.EnterScope(parent)
+		defer restore()
+	} else {
+		scope, _ = ctx.EnterBlock(false, "")
+		defer ctx.ExitBlock(-1)
+	}
+	// the arguments and the variables first assigned in the function belong to the function, thus a
+	// closure declared in the function keep its own copy of them
+	fscope := scope.(*xScope)
+	fscope.isolated = true
+	var traceArgs []*args.FunctionArg
+	for i, val := range values {
+		if !val.set {
+			// default value is evaluated in the function scope thus it can refer to the preceding arguments
+			x := fn.Defaults[i]
+			if val.v, val.k, err = x.Evaluate(ctx); err != nil {
+				return nil, 0, cookErrors.NewDiagnostic(x.Position(), cookErrors.CodeRuntime, err)
+			}
+		}
+		fscope.declare(fn.Args[i].Name, val.v, val.k)
+		traceArgs = append(traceArgs, &args.FunctionArg{Val: val.v, Kind: val.k})
+	}
+	if fn.Variadic {
+		if rest == nil {
+			rest = make([]interface{}, 0)
+		}
+		fscope.declare(fn.Args[fixed].Name, rest, reflect.Slice)
+		traceArgs = append(traceArgs, &args.FunctionArg{Val: rest, Kind: reflect.Slice})
+	}
+	// transformation function is executed for each element thus only declared function is traced
+	if fn.Name != "" && ctx.Tracing() {
+		ctx.Trace(fn.position(), fn.Name+"("+formatArgs(traceArgs, ", ")+")")
+	}
 	if fn.Lambda == token.LAMBDA {
-		return fn.X.Evaluate(ctx)
+		if v, kind, err = fn.X.Evaluate(ctx); err != nil {
+			return nil, 0, cookErrors.NewDiagnostic(fn.X.Position(), cookErrors.CodeRuntime, err)
+		}
+		return v, kind, nil
 	} else if err = fn.Insts.Evaluate(ctx); err == nil {
 		v, kind = ctx.GetReturnValue()
 	}
@@ -1,8 +1,10 @@
 package ast
 
 import (
+	"bytes"
 	"reflect"
 	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/cozees/cook/pkg/cook/token"
@@ -110,3 +112,13 @@ func TestCook(t *testing.T) {
 		expectVar(t, cook.ctx, varc.Name, exc, reflect.Float64)
 	}
 }
+
+func TestPrefixWriter(t *testing.T) {
+	buf := bytes.NewBufferString("")
+	pw := &prefixWriter{mu: &sync.Mutex{}, w: buf, prefix: []byte("[build] ")}
+	pw.Write([]byte("first line\nsecond "))
+	require.Equal(t, "[build] first line\n", buf.String())
+	pw.Write([]byte("line\nthird"))
+	require.NoError(t, pw.Flush())
+	require.Equal(t, "[build] first line\n[build] second line\n[build] third\n", buf.String())
+}
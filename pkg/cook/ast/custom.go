@@ -1,12 +1,12 @@
 package ast
 
 import (
-	"fmt"
 	"io"
 	"reflect"
 	"strings"
 
 	"github.com/cozees/cook/pkg/cook/token"
+	cookErrors "github.com/cozees/cook/pkg/errors"
 )
 
 const (
@@ -29,6 +29,39 @@ func (it *iTransform) Transform(ctx Context, index interface{}) (interface{}, re
 	}
 }
 
+// Closure is a function used as a value, e.g. assigned to a variable, given as an argument or
+// returned by another function. A function literal capture the scope where it is evaluated thus it
+// can refer to the variables of the enclosing function even after the function has returned. A
+// function declared in Cookfile does not capture any scope.
+type Closure struct {
+	Fn    *Function
+	scope Scope
+}
+
+func (cl *Closure) String() string { return codeOf(cl.Fn) }
+
+// elemKind return the kind of an element of an array or a map, a closure is a function.
+func elemKind(v reflect.Value) reflect.Kind {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return reflect.Invalid
+	} else if _, ok := v.Interface().(*Closure); ok {
+		return reflect.Func
+	}
+	return v.Kind()
+}
+
+// Execute call the function with the arguments given by the caller.
+func (cl *Closure) Execute(ctx Context, pargs []Node) (interface{}, reflect.Kind, error) {
+	return cl.Fn.execute(ctx, cl.scope, pargs)
+}
+
+func (cl *Closure) execute(ctx Context, numArgs int, named []*NamedArg, farg argumentSetter) (interface{}, reflect.Kind, error) {
+	return cl.Fn.internalExecute(ctx, cl.scope, numArgs, named, farg)
+}
+
 //
 type StringInterpolation struct {
 	*Base
@@ -51,7 +84,7 @@ func (si *StringInterpolation) Evaluate(ctx Context) (interface{}, reflect.Kind,
 		if v, k, err := si.nodes[i].Evaluate(ctx); err != nil {
 			return nil, 0, err
 		} else if str, err := convertToString(ctx, v, k); err != nil {
-			return nil, 0, fmt.Errorf("%s: %w", si.ErrPos(), err)
+			return nil, 0, cookErrors.NewDiagnostic(si.Position(), cookErrors.CodeType, err)
 		} else {
 			builder.WriteString(str)
 		}
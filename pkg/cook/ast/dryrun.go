@@ -0,0 +1,138 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cozees/cook/pkg/cook/token"
+	"github.com/cozees/cook/pkg/runtime/args"
+	"github.com/cozees/cook/pkg/runtime/function"
+)
+
+// dryRun return the call as it would be executed with every argument expanded. An empty string is
+// return if the call is safe to be executed in dry-run mode, e.g. a target, a function declared in
+// Cookfile or a built-in function which does not modify anything.
+func (c *Call) dryRun(ctx Context) (string, error) {
+	if c.FuncLit != nil {
+		return "", nil
+	} else if c.Kind == token.AT {
+		if _, k, _ := ctx.GetVariable(c.Name); k == reflect.Func || ctx.GetTarget(c.Name) != nil {
+			return "", nil
+		} else if f := ctx.GetCommand(c.Name); f == nil || function.IsReadOnly(f) {
+			return "", nil
+		}
+	}
+	return c.describe(ctx)
+}
+
+func (c *Call) describe(ctx Context) (string, error) {
+	if c.Kind == token.HASH {
+		args, err := c.args(ctx)
+		if err != nil {
+			return "", err
+		}
+		return describeCommand(c.Name, args), nil
+	}
+	args, err := c.funcArgs(ctx)
+	if err != nil {
+		return "", err
+	}
+	return describeBuiltIn(c.Name, args), nil
+}
+
+func describeCommand(name string, args []string) string {
+	buf := strings.Builder{}
+	buf.WriteByte('#')
+	buf.WriteString(name)
+	for _, arg := range args {
+		buf.WriteByte(' ')
+		buf.WriteString(quoteArg(arg))
+	}
+	return buf.String()
+}
+
+func describeBuiltIn(name string, fargs []*args.FunctionArg) string {
+	if len(fargs) == 0 {
+		return "@" + name
+	}
+	return "@" + name + " " + formatArgs(fargs, " ")
+}
+
+func formatArgs(fargs []*args.FunctionArg, sep string) string {
+	buf := strings.Builder{}
+	for i, arg := range fargs {
+		if i > 0 {
+			buf.WriteString(sep)
+		}
+		if arg.Name != "" {
+			buf.WriteString(arg.Name)
+			buf.WriteByte('=')
+		}
+		if arg.Kind == reflect.String {
+			buf.WriteString(quoteArg(arg.Val.(string)))
+		} else {
+			buf.WriteString(fmt.Sprintf("%v", arg.Val))
+		}
+	}
+	return buf.String()
+}
+
+// describe return the expression as it would be executed in dry-run mode.
+func describe(ctx Context, n Node) (string, error) {
+	switch x := n.(type) {
+	case *Call:
+		return x.describe(ctx)
+	case *Pipe:
+		left, err := x.X.describe(ctx)
+		if err != nil || x.Y == nil {
+			return left, err
+		}
+		right, err := describe(ctx, x.Y)
+		if err != nil {
+			return "", err
+		}
+		return left + " | " + right, nil
+	case *RedirectTo:
+		files, err := stringOf(ctx, x.Files...)
+		if err != nil {
+			return "", err
+		}
+		caller, err := describe(ctx, x.Caller)
+		if err != nil {
+			return "", err
+		}
+		op := " > "
+		if x.Append {
+			op = " >> "
+		}
+		for i := range files {
+			files[i] = quoteArg(files[i])
+		}
+		return caller + op + strings.Join(files, " "), nil
+	default:
+		v, k, err := n.Evaluate(ctx)
+		if err != nil {
+			return "", err
+		} else if k == reflect.String {
+			return quoteArg(v.(string)), nil
+		}
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+func printDryRun(ctx Context, desc string, err error) (interface{}, reflect.Kind, error) {
+	if err != nil {
+		return nil, 0, err
+	}
+	fmt.Fprintln(ctx.Stdout(), desc)
+	return "", reflect.String, nil
+}
+
+// quoteArg wrap the argument in single quote if it is empty or contain whitespace or quote.
+func quoteArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", "\\'") + "'"
+}
@@ -0,0 +1,115 @@
+package ast
+
+import (
+	"os"
+	"reflect"
+	"sort"
+
+	cookErrors "github.com/cozees/cook/pkg/errors"
+)
+
+// ExportStatement export a variable to the environment of every command executed afterward, e.g.
+// export GOOS = 'linux'. X is nil when an existing variable is exported.
+type ExportStatement struct {
+	*Base
+	Name *Ident
+	X    Node
+}
+
+// UnsetStatement remove variables from the Cookfile as well as from the environment, e.g. unset GOOS
+type UnsetStatement struct {
+	*Base
+	Names []*Ident
+}
+
+func (es *ExportStatement) Evaluate(ctx Context) error {
+	name := es.Name.Name
+	var v interface{}
+	var k reflect.Kind
+	if es.X == nil {
+		if v, k, _ = ctx.GetVariable(name); k == reflect.Invalid {
+			return cookErrors.Errorf(es.Name.Position(), cookErrors.CodeUndefined, "variable %s is not defined", name)
+		}
+	} else {
+		var err error
+		if v, k, err = es.X.Evaluate(ctx); err != nil {
+			return err
+		}
+	}
+	if _, err := convertToString(ctx, v, k); err != nil {
+		return cookErrors.NewDiagnostic(es.Position(), cookErrors.CodeType, err)
+	}
+	if es.X != nil {
+		ctx.SetVariable(name, v, k, nil)
+	}
+	if xc, ok := ctx.(*xContext); ok {
+		xc.cook.envMu.Lock()
+		defer xc.cook.envMu.Unlock()
+		if xc.cook.exports == nil {
+			xc.cook.exports = make(map[string]bool)
+		}
+		xc.cook.exports[name] = true
+	}
+	return nil
+}
+
+func (us *UnsetStatement) Evaluate(ctx Context) error {
+	xc, _ := ctx.(*xContext)
+	for _, ident := range us.Names {
+		if xc != nil {
+			xc.scope.unset(ident.Name)
+			xc.cook.envMu.Lock()
+			delete(xc.cook.exports, ident.Name)
+			xc.cook.envMu.Unlock()
+		}
+		if err := os.Unsetenv(ident.Name); err != nil {
+			return cookErrors.NewDiagnostic(ident.Position(), cookErrors.CodeRuntime, err)
+		}
+	}
+	return nil
+}
+
+// unset remove the variable from the scope where it is declared.
+func (xs *xScope) unset(name string) {
+	if owner := xs.owner(name); owner != nil {
+		if owner.mu != nil {
+			owner.mu.Lock()
+			defer owner.mu.Unlock()
+		}
+		delete(owner.vars, name)
+	}
+}
+
+// environ return the environment of a command, it is the environment of cook along with the current
+// value of every exported variable. environ return nil if no variable is exported thus the command
+// simply inherit the environment of cook.
+func environ(ctx Context) ([]string, error) {
+	xc, ok := ctx.(*xContext)
+	if !ok {
+		return nil, nil
+	}
+	xc.cook.envMu.Lock()
+	names := make([]string, 0, len(xc.cook.exports))
+	for name := range xc.cook.exports {
+		names = append(names, name)
+	}
+	xc.cook.envMu.Unlock()
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sort.Strings(names)
+	env := os.Environ()
+	for _, name := range names {
+		// a variable declared in a scope which is not visible from ctx is not given to the command
+		v, k, fromEnv := ctx.GetVariable(name)
+		if k == reflect.Invalid || fromEnv {
+			continue
+		}
+		s, err := convertToString(ctx, v, k)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, name+"="+s)
+	}
+	return env, nil
+}
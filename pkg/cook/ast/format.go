@@ -0,0 +1,230 @@
+package ast
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/cozees/cook/pkg/cook/token"
+)
+
+// directive is the include or trace directive placed at the top of a Cookfile.
+type directive struct {
+	lit *BasicLit
+}
+
+func (d *directive) String() string { return codeOf(d) }
+
+func (d *directive) Visit(cb CodeBuilder) {
+	if d.lit.Kind == token.TRACE {
+		cb.WriteString("trace")
+	} else {
+		cb.WriteString("include ")
+		d.lit.Visit(cb)
+	}
+}
+
+// formatItem is a directive, a statement, a target or a function declared at the top level.
+type formatItem struct {
+	offs int
+	code Code
+	decl bool
+}
+
+// Format return the source of the Cookfile in its canonical layout. Statements are indented with
+// 4 spaces, comments recorded in file are kept at their place and array or map literal which does
+// not fit in maxLength is written one element per line. directives are the include and trace
+// directive in the order of declaration, the Kind of include directive is token.STRING while trace
+// directive is token.TRACE.
+func Format(c Cook, file *token.File, src []byte, directives []*BasicLit, maxLength int) []byte {
+	b := &builder{
+		Builder:   &strings.Builder{},
+		indent:    "    ",
+		formatter: true,
+		maxLength: maxLength,
+		file:      file,
+		src:       src,
+		comments:  file.Comments(),
+	}
+	ck := c.(*cook)
+	items := make([]formatItem, 0, len(directives)+len(ck.Insts.Stmts)+len(ck.targetIndexes)+len(ck.fns))
+	for _, d := range directives {
+		items = append(items, formatItem{offs: d.Offset, code: &directive{lit: d}})
+	}
+	offs := 0
+	for _, stmt := range ck.Insts.Stmts {
+		offs = statementOffset(stmt, offs)
+		items = append(items, formatItem{offs: offs, code: stmt})
+	}
+	targets := append(append([]*Target{}, ck.initializeTargets...), ck.finalizeTargets...)
+	for _, t := range append(targets, ck.Targets()...) {
+		items = append(items, formatItem{offs: t.Offset, code: t, decl: true})
+	}
+	for _, fn := range ck.fns {
+		if fn.Base != nil {
+			items = append(items, formatItem{offs: fn.Offset, code: fn, decl: true})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].offs < items[j].offs })
+
+	inDirective, afterDecl := true, false
+	for i, item := range items {
+		if i+1 < len(items) {
+			b.limit = items[i+1].offs
+		} else {
+			b.limit = len(src)
+		}
+		_, isDirective := item.code.(*directive)
+		// directives, targets and functions are separated from the rest of the file by an empty line
+		separate := item.decl || afterDecl || (inDirective && !isDirective && b.Len() > 0)
+		inDirective, afterDecl = inDirective && isDirective, item.decl
+		if !separate {
+			b.writeStatement(item.code, item.offs, b.limit, b.Len() == 0)
+			continue
+		}
+		b.blankLine()
+		if b.writeComments(item.offs, true, false) && b.hasBlankAbove(item.offs) {
+			b.blankLine()
+		}
+		if !item.decl {
+			b.writeStatement(item.code, item.offs, b.limit, true)
+			continue
+		}
+		item.code.Visit(b)
+		if !strings.HasSuffix(b.String(), "\n") {
+			b.writeTrailing(b.limit)
+			b.WriteByte('\n')
+		}
+	}
+	b.writeComments(len(src)+1, b.Len() == 0, false)
+	return []byte(b.String())
+}
+
+// formatterOf return the builder if cb is used by Format otherwise nil.
+func formatterOf(cb CodeBuilder) *builder {
+	if b, ok := cb.(*builder); ok && b.file != nil {
+		return b
+	}
+	return nil
+}
+
+// statementOffset return the offset of the statement in the source or prev if the statement
+// does not carry its position.
+func statementOffset(stmt Code, prev int) int {
+	if n, ok := stmt.(interface{ Position() token.Position }); ok {
+		if pos := n.Position(); pos.Line > 0 {
+			return pos.Offset
+		}
+	}
+	return prev
+}
+
+func (b *builder) column() int {
+	s := b.String()
+	return len(s) - strings.LastIndexByte(s, '\n') - 1
+}
+
+// blankLine end the output with an empty line unless the output is empty or already end with one.
+func (b *builder) blankLine() {
+	if s := b.String(); s != "" && !strings.HasSuffix(s, "\n\n") {
+		b.WriteByte('\n')
+	}
+}
+
+// hasBlankAbove report whether the source line placed above offset is an empty line.
+func (b *builder) hasBlankAbove(offs int) bool {
+	i := bytes.LastIndexByte(b.src[:offs], '\n')
+	if i <= 0 {
+		return false
+	}
+	j := bytes.LastIndexByte(b.src[:i], '\n')
+	return len(bytes.TrimSpace(b.src[j+1:i])) == 0
+}
+
+// isTrailing report whether there is code placed before offset on the same source line.
+func (b *builder) isTrailing(offs int) bool {
+	i := bytes.LastIndexByte(b.src[:offs], '\n')
+	return len(bytes.TrimSpace(b.src[i+1:offs])) > 0
+}
+
+func (b *builder) writeComment(text string) {
+	if strings.HasPrefix(text, "//") {
+		text = strings.TrimRight(text, " \t\r")
+	}
+	b.WriteString(text)
+}
+
+// writeComments write every pending comment placed before offs on its own line. If first is true
+// no empty line is written before the first comment. If indented is true, writing stop at the first
+// comment which start at the beginning of a line.
+func (b *builder) writeComments(offs int, first, indented bool) (written bool) {
+	for len(b.comments) > 0 && b.comments[0].Offset < offs {
+		c := b.comments[0]
+		if indented && b.file.Position(c.Offset).Column == 1 {
+			break
+		}
+		b.comments = b.comments[1:]
+		if (written || !first) && b.hasBlankAbove(c.Offset) {
+			b.blankLine()
+		}
+		b.WriteIndent()
+		b.writeComment(c.Text)
+		b.WriteByte('\n')
+		written = true
+	}
+	return written
+}
+
+// writeTrailing write the pending comment placed at the end of the line of the code just written.
+func (b *builder) writeTrailing(next int) {
+	if len(b.comments) > 0 && b.comments[0].Offset < next && b.isTrailing(b.comments[0].Offset) {
+		b.WriteByte(' ')
+		b.writeComment(b.comments[0].Text)
+		b.comments = b.comments[1:]
+	}
+}
+
+// writeStatement write the comments placed above the statement followed by the statement itself,
+// next is the offset of the following statement.
+func (b *builder) writeStatement(stmt Code, offs, next int, first bool) {
+	if (b.writeComments(offs, first, false) || !first) && b.hasBlankAbove(offs) {
+		b.blankLine()
+	}
+	b.WriteIndent()
+	stmt.Visit(b)
+	b.writeTrailing(next)
+	b.WriteByte('\n')
+}
+
+// formatBlock write the block statement in the canonical layout, the statements of a target block
+// end at the limit which is the offset of the following top level declaration.
+func (b *builder) formatBlock(bs *BlockStatement) {
+	end := bs.End
+	if bs.plain {
+		end = b.limit
+	} else {
+		next := end
+		if len(bs.Stmts) > 0 {
+			next = statementOffset(bs.Stmts[0], next)
+		}
+		b.WriteString(" {")
+		b.writeTrailing(next)
+		b.WriteByte('\n')
+	}
+	b.IdentBy(1)
+	offs := 0
+	for i, stmt := range bs.Stmts {
+		offs = statementOffset(stmt, offs)
+		next := end
+		if i+1 < len(bs.Stmts) {
+			next = statementOffset(bs.Stmts[i+1], offs)
+		}
+		b.writeStatement(stmt, offs, next, i == 0)
+	}
+	b.writeComments(end, len(bs.Stmts) == 0, bs.plain)
+	b.IdentBy(-1)
+	if !bs.plain {
+		b.WriteIndent()
+		b.WriteByte('}')
+	}
+}
@@ -0,0 +1,176 @@
+package ast
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	cookErrors "github.com/cozees/cook/pkg/errors"
+	"github.com/cozees/cook/pkg/runtime/glob"
+)
+
+// stateFile is a file in the directory of the main Cookfile which record the content hash of each
+// target inputs after the target was executed successfully.
+const stateFile = ".cookstate"
+
+// buildState hold content hash of input files for each target, keyed by target name then file path.
+type buildState struct {
+	mu      sync.Mutex
+	file    string
+	changed bool
+	Targets map[string]map[string]string `json:"targets"`
+}
+
+func loadBuildState(file string) *buildState {
+	bs := &buildState{file: file, Targets: make(map[string]map[string]string)}
+	if b, err := ioutil.ReadFile(file); err == nil {
+		// a corrupted state file only cause target to be executed again
+		if err = json.Unmarshal(b, bs); err != nil || bs.Targets == nil {
+			bs.Targets = make(map[string]map[string]string)
+		}
+	}
+	return bs
+}
+
+func (bs *buildState) record(name string, hashes map[string]string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.Targets[name] = hashes
+	bs.changed = true
+}
+
+func (bs *buildState) save() error {
+	if !bs.changed {
+		return nil
+	}
+	b, err := json.MarshalIndent(bs, "", "  ")
+	if err != nil {
+		return err
+	}
+	bs.changed = false
+	return ioutil.WriteFile(bs.file, b, 0644)
+}
+
+// expandFiles evaluate each node into a list of file path. A string value which contain glob pattern
+// is expanded into the files it matched.
+func expandFiles(ctx Context, nodes []Node) ([]string, error) {
+	var files []string
+	for _, n := range nodes {
+		v, k, err := n.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var paths []string
+		switch k {
+		case reflect.String:
+			paths = []string{v.(string)}
+		case reflect.Slice:
+			if paths, err = expandArrayTo(ctx, reflect.ValueOf(v), nil); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, cookErrors.Errorf(n.Position(), cookErrors.CodeType, "file path must be a string or an array of string")
+		}
+		for _, p := range paths {
+			if glob.HasMeta(p) {
+				if matches, err := glob.Glob(p); err != nil {
+					return nil, cookErrors.NewDiagnostic(n.Position(), cookErrors.CodeRuntime, err)
+				} else {
+					files = append(files, matches...)
+				}
+			} else {
+				files = append(files, p)
+			}
+		}
+	}
+	return files, nil
+}
+
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFiles(files []string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	for _, file := range files {
+		if stat, err := os.Stat(file); err != nil {
+			return nil, err
+		} else if stat.IsDir() {
+			continue
+		} else if hashes[file], err = hashFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
+
+// upToDate return true if the target declare its inputs and outputs and those outputs does not need
+// to be rebuild, either every output is newer than every input or content of the inputs is unchanged
+// since the last successful execution. A target without any input is always executed as there is
+// nothing to compare its outputs with. The input files are return so their hashes can be recorded
+// after the target is executed.
+func (t *Target) upToDate(ctx Context, state *buildState) (bool, []string, error) {
+	if len(t.outputs) == 0 || len(t.inputs) == 0 {
+		return false, nil, nil
+	}
+	inputs, err := expandFiles(ctx, t.inputs)
+	if err != nil {
+		return false, nil, err
+	} else if len(inputs) == 0 {
+		return false, nil, nil
+	}
+	outputs, err := expandFiles(ctx, t.outputs)
+	if err != nil {
+		return false, nil, err
+	}
+	var oldest time.Time
+	for i, file := range outputs {
+		stat, err := os.Stat(file)
+		if err != nil {
+			// output is missing
+			return false, inputs, nil
+		} else if i == 0 || stat.ModTime().Before(oldest) {
+			oldest = stat.ModTime()
+		}
+	}
+	newer := false
+	for _, file := range inputs {
+		stat, err := os.Stat(file)
+		if err != nil {
+			// input is missing, let the target decide what to do
+			return false, inputs, nil
+		} else if !stat.ModTime().Before(oldest) {
+			newer = true
+		}
+	}
+	if !newer {
+		return true, inputs, nil
+	}
+	// some inputs were touched after the outputs, compare their content with the last execution
+	state.mu.Lock()
+	recorded, ok := state.Targets[t.name]
+	state.mu.Unlock()
+	if !ok || len(recorded) == 0 {
+		return false, inputs, nil
+	}
+	hashes, err := hashFiles(inputs)
+	if err != nil {
+		return false, inputs, nil
+	}
+	return reflect.DeepEqual(recorded, hashes), inputs, nil
+}
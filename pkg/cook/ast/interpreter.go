@@ -0,0 +1,124 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cozees/cook/pkg/cook/token"
+	cookErrors "github.com/cozees/cook/pkg/errors"
+)
+
+// Interpreter evaluate statements and expressions one after another in the same context thus a
+// variable assigned by a statement is available to the following ones. It is used by the REPL.
+type Interpreter struct {
+	cook *cook
+	ctx  *xContext
+}
+
+// NewInterpreter return an interpreter of the Cook, the top level statements of the Cook are
+// evaluated first so its global variables are available along with its targets and functions.
+func NewInterpreter(c Cook, pargs map[string]interface{}) (*Interpreter, error) {
+	ck := c.(*cook)
+	ck.ctx = ck.renewContext()
+	for name, v := range pargs {
+		ck.ctx.scope.SetVariable(name, v, reflect.ValueOf(v).Kind(), nil)
+	}
+	if err := ck.Insts.Evaluate(ck.ctx); err != nil {
+		return nil, err
+	}
+	return &Interpreter{cook: ck, ctx: ck.ctx}, nil
+}
+
+// Cook return the Cook which new target and function declared in the interpreter is added to.
+func (in *Interpreter) Cook() Cook { return in.cook }
+
+// Evaluate evaluate the statements of the block and return the value of the last statement if it
+// is an expression or an assignment, otherwise the kind is reflect.Invalid.
+func (in *Interpreter) Evaluate(bs *BlockStatement) (interface{}, reflect.Kind, error) {
+	if len(bs.Stmts) == 0 {
+		return nil, reflect.Invalid, nil
+	}
+	n := len(bs.Stmts) - 1
+	if err := (&BlockStatement{Stmts: bs.Stmts[:n]}).Evaluate(in.ctx); err != nil {
+		return nil, 0, err
+	}
+	switch last := bs.Stmts[n].(type) {
+	case *ExprWrapperStatement:
+		v, k, err := last.X.Evaluate(in.ctx)
+		if err != nil {
+			return nil, 0, cookErrors.NewDiagnostic(last.Position(), cookErrors.CodeRuntime, err)
+		}
+		return v, k, nil
+	case *AssignStatement:
+		if err := last.Evaluate(in.ctx); err != nil {
+			return nil, 0, cookErrors.NewDiagnostic(last.Position(), cookErrors.CodeRuntime, err)
+		} else if id, ok := last.Ident.(*Ident); ok {
+			v, k, _ := in.ctx.GetVariable(id.Name)
+			return v, k, nil
+		}
+		return last.Ident.Evaluate(in.ctx)
+	default:
+		return nil, reflect.Invalid, (&BlockStatement{Stmts: bs.Stmts[n:]}).Evaluate(in.ctx)
+	}
+}
+
+// EvaluateExpr evaluate the expression and return its value.
+func (in *Interpreter) EvaluateExpr(x Node) (interface{}, reflect.Kind, error) {
+	v, k, err := x.Evaluate(in.ctx)
+	if err != nil {
+		return nil, 0, cookErrors.NewDiagnostic(x.Position(), cookErrors.CodeRuntime, err)
+	}
+	return v, k, nil
+}
+
+// KindName return the name of the built-in type which represent the kind.
+func KindName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int64:
+		return token.TINTEGER.String()
+	case reflect.Float64:
+		return token.TFLOAT.String()
+	case reflect.String:
+		return token.TSTRING.String()
+	case reflect.Bool:
+		return token.TBOOLEAN.String()
+	case reflect.Array, reflect.Slice:
+		return token.TARRAY.String()
+	case reflect.Map:
+		return token.TMAP.String()
+	case reflect.Func:
+		return "function"
+	default:
+		return token.TOBJECT.String()
+	}
+}
+
+// FormatValue return the value written as a Cook literal, map keys are sorted.
+func FormatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return "'" + strings.ReplaceAll(val, "'", "\\'") + "'"
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Slice:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = FormatValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Map:
+		items := make([]string, 0, rv.Len())
+		for iter := rv.MapRange(); iter.Next(); {
+			items = append(items, FormatValue(iter.Key().Interface())+": "+FormatValue(iter.Value().Interface()))
+		}
+		sort.Strings(items)
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
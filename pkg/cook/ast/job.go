@@ -0,0 +1,265 @@
+package ast
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	cookErrors "github.com/cozees/cook/pkg/errors"
+)
+
+type (
+	// A node represent a call started in the background, e.g. server = spawn #python '-m' 'http.server'
+	Spawn struct {
+		*Base
+		X Node
+	}
+
+	// A node represent waiting for a background job, X is nil when waiting for every job
+	Wait struct {
+		*Base
+		X Node
+	}
+)
+
+// Job is a call executed in the background by spawn. The result of the call is available once the
+// job is finished.
+type Job struct {
+	id   int
+	x    Node
+	done chan struct{}
+
+	v   interface{}
+	k   reflect.Kind
+	err error
+
+	mu        sync.Mutex
+	cmds      []*exec.Cmd
+	cancelled bool
+}
+
+func (j *Job) String() string { return fmt.Sprintf("job %d (%s)", j.id, j.x) }
+
+// start start the command which is executed by the job in its own process group thus the command
+// and every process it started can be killed once the job is cancelled.
+func (j *Job) start(cmd *exec.Cmd) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.cancelled {
+		return fmt.Errorf("job %d is cancelled", j.id)
+	}
+	// a background command must not read from the terminal
+	if cmd.Stdin == os.Stdin {
+		cmd.Stdin = nil
+	}
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	j.cmds = append(j.cmds, cmd)
+	return nil
+}
+
+// kill every command started by the job then wait for the job to finish. A command started after
+// the job is killed failed immediately.
+func (j *Job) kill() {
+	j.mu.Lock()
+	j.cancelled = true
+	for _, cmd := range j.cmds {
+		killProcessGroup(cmd)
+	}
+	j.mu.Unlock()
+	<-j.done
+}
+
+func (j *Job) wait() (interface{}, reflect.Kind, error) {
+	<-j.done
+	return j.v, j.k, j.err
+}
+
+// jobOf return the job executed by ctx or nil if ctx does not belong to a background job.
+func jobOf(ctx Context) *Job {
+	if xc, ok := ctx.(*xContext); ok {
+		return xc.job
+	}
+	return nil
+}
+
+// killJobs kill the jobs started after the first n jobs, it is called when a target end.
+func (xc *xContext) killJobs(n int) {
+	for _, j := range xc.jobs[n:] {
+		j.kill()
+	}
+	xc.jobs = xc.jobs[:n]
+}
+
+// Spawn Evaluate start the call in a goroutine and return the job immediately. The arguments of the
+// call are evaluated before the call is started.
+func (sp *Spawn) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
+	xc, ok := ctx.(*xContext)
+	if !ok {
+		panic("cook internal error: spawn require the cook context")
+	}
+	x, err := bind(ctx, sp.X)
+	if err != nil {
+		return nil, 0, err
+	}
+	// variables can now be accessed by more than one goroutine
+	global := xc.scope
+	for global.parent != nil {
+		global = global.parent
+	}
+	if global.mu == nil {
+		global.mu = &sync.RWMutex{}
+	}
+	id := atomic.AddInt32(&xc.cook.numJobs, 1)
+	j := &Job{id: int(id), x: sp.X, done: make(chan struct{})}
+	fork := &xContext{
+		scope:      xc.scope,
+		cook:       xc.cook,
+		stdout:     xc.stdout,
+		stderr:     xc.stderr,
+		continueAt: -1,
+		breakAt:    -1,
+		job:        j,
+	}
+	xc.jobs = append(xc.jobs, j)
+	go func() {
+		defer close(j.done)
+		j.v, j.k, j.err = x.Evaluate(fork)
+		fork.killJobs(0)
+	}()
+	return j, reflect.Ptr, nil
+}
+
+// Wait Evaluate wait for a job or an array of jobs and return its result, the result of each job in
+// an array is return when waiting for more than one job. Every job started in the current context is
+// waited when X is nil. Once every job is finished, the error of the first failed job is returned.
+func (w *Wait) Evaluate(ctx Context) (interface{}, reflect.Kind, error) {
+	var jobs []*Job
+	if w.X == nil {
+		if xc, ok := ctx.(*xContext); ok {
+			jobs = xc.jobs
+		}
+	} else {
+		v, k, err := w.X.Evaluate(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch k {
+		case reflect.Ptr:
+			if j, ok := v.(*Job); ok {
+				jv, jk, err := j.wait()
+				if err != nil {
+					return nil, 0, cookErrors.NewDiagnostic(w.Position(), cookErrors.CodeRuntime, err)
+				}
+				return jv, jk, nil
+			}
+		case reflect.Slice:
+			for _, item := range v.([]interface{}) {
+				if j, ok := item.(*Job); ok {
+					jobs = append(jobs, j)
+				} else {
+					return nil, 0, cookErrors.Errorf(w.Position(), cookErrors.CodeType, "wait require job, given %s", FormatValue(item))
+				}
+			}
+		}
+		if jobs == nil && k != reflect.Slice {
+			return nil, 0, cookErrors.Errorf(w.Position(), cookErrors.CodeType, "wait require job or array of jobs, given %s", FormatValue(v))
+		}
+	}
+	results := make([]interface{}, len(jobs))
+	var firstErr error
+	for i, j := range jobs {
+		v, _, err := j.wait()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		results[i] = v
+	}
+	if firstErr != nil {
+		return nil, 0, cookErrors.NewDiagnostic(w.Position(), cookErrors.CodeRuntime, firstErr)
+	}
+	return results, reflect.Slice, nil
+}
+
+// evaluated is a node whose value is already evaluated.
+type evaluated struct {
+	Node
+	v interface{}
+	k reflect.Kind
+}
+
+func (e *evaluated) Evaluate(ctx Context) (interface{}, reflect.Kind, error) { return e.v, e.k, nil }
+
+// bind return a copy of the call, the pipe or the redirect whose arguments are evaluated, thus a job
+// use the value of the arguments at the time it is spawned.
+func bind(ctx Context, x Node) (Node, error) {
+	eval := func(n Node) (Node, error) {
+		if n == nil {
+			// line continuation
+			return nil, nil
+		} else if na, ok := n.(*NamedArg); ok {
+			v, k, err := na.X.Evaluate(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &NamedArg{Base: na.Base, Name: na.Name, X: &evaluated{Node: na.X, v: v, k: k}}, nil
+		}
+		v, k, err := n.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &evaluated{Node: n, v: v, k: k}, nil
+	}
+	switch n := x.(type) {
+	case *Call:
+		c := *n
+		c.Args = make([]Node, len(n.Args))
+		for i, arg := range n.Args {
+			var err error
+			if c.Args[i], err = eval(arg); err != nil {
+				return nil, err
+			}
+		}
+		c.Options = make([]*NamedArg, len(n.Options))
+		for i, opt := range n.Options {
+			na, err := eval(opt)
+			if err != nil {
+				return nil, err
+			}
+			c.Options[i] = na.(*NamedArg)
+		}
+		return &c, nil
+	case *Pipe:
+		pp := *n
+		bx, err := bind(ctx, n.X)
+		if err != nil {
+			return nil, err
+		}
+		pp.X = bx.(*Call)
+		if n.Y != nil {
+			if pp.Y, err = bind(ctx, n.Y); err != nil {
+				return nil, err
+			}
+		}
+		return &pp, nil
+	case *RedirectTo:
+		rt := *n
+		var err error
+		if rt.Caller, err = bind(ctx, n.Caller); err != nil {
+			return nil, err
+		}
+		rt.Files = make([]Node, len(n.Files))
+		for i, f := range n.Files {
+			if rt.Files[i], err = eval(f); err != nil {
+				return nil, err
+			}
+		}
+		return &rt, nil
+	}
+	panic(fmt.Sprintf("cook internal error: spawn does not support %T", x))
+}
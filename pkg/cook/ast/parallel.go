@@ -0,0 +1,131 @@
+package ast
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// prefixWriter write each complete line to w prefixed with the target name. A line is written at
+// once while holding the lock shared by every target, so output from targets executed in parallel
+// never interleave within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.buf = append(pw.buf, p...)
+	i := bytes.LastIndexByte(pw.buf, '\n')
+	if i == -1 {
+		return len(p), nil
+	}
+	err := pw.writeLines(pw.buf[:i+1])
+	pw.buf = pw.buf[i+1:]
+	return len(p), err
+}
+
+// Flush write the remaining incomplete line if there is any.
+func (pw *prefixWriter) Flush() error {
+	if len(pw.buf) == 0 {
+		return nil
+	}
+	err := pw.writeLines(append(pw.buf, '\n'))
+	pw.buf = nil
+	return err
+}
+
+func (pw *prefixWriter) writeLines(lines []byte) error {
+	out := make([]byte, 0, len(lines)+len(pw.prefix)*bytes.Count(lines, []byte{'\n'}))
+	for len(lines) > 0 {
+		i := bytes.IndexByte(lines, '\n')
+		out = append(out, pw.prefix...)
+		out = append(out, lines[:i+1]...)
+		lines = lines[i+1:]
+	}
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	_, err := pw.w.Write(out)
+	return err
+}
+
+// executeParallel execute the targets using at most c.opts.Jobs goroutines. A target start only
+// after all of its dependencies are finished. Each target is executed with its own context that
+// share only the global scope, and its output is prefixed with the target name. Variables declared
+// by a target are kept to itself while it is running then declared globally once it is finished,
+// thus the targets depending on it read them as if the targets were executed one by one. Once a
+// target failed no other target is started and the first error is returned.
+func (c *cook) executeParallel(targets []*Target, state *buildState) error {
+	c.ctx.scope.mu = &sync.RWMutex{}
+	defer func() { c.ctx.scope.mu = nil }()
+
+	done := make(map[*Target]chan struct{}, len(targets))
+	for _, t := range targets {
+		done[t] = make(chan struct{})
+	}
+	var (
+		wg       sync.WaitGroup
+		outMu    sync.Mutex
+		errMu    sync.Mutex
+		firstErr error
+		slots    = make(chan struct{}, c.opts.Jobs)
+	)
+	failed := func() bool {
+		errMu.Lock()
+		defer errMu.Unlock()
+		return firstErr != nil
+	}
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t *Target) {
+			defer wg.Done()
+			defer close(done[t])
+			for _, dep := range t.deps {
+				if ch, ok := done[c.getTarget(dep.Name)]; ok {
+					<-ch
+				}
+			}
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if failed() {
+				return
+			}
+			prefix := []byte("[" + t.name + "] ")
+			stdout := &prefixWriter{mu: &outMu, w: os.Stdout, prefix: prefix}
+			stderr := &prefixWriter{mu: &outMu, w: os.Stderr, prefix: prefix}
+			ctx := c.forkContext(stdout, stderr)
+			scope, _ := ctx.EnterBlock(false, "")
+			scope.(*xScope).isolated = true
+			err := c.executeTarget(ctx, t, state)
+			ctx.ExitBlock(-1)
+			c.ctx.scope.merge(scope.(*xScope))
+			stdout.Flush()
+			stderr.Flush()
+			if err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+			}
+		}(t)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// forkContext create a new context which share the global scope with c.ctx but has its own block
+// scope and loop state so it can be used by a goroutine.
+func (c *cook) forkContext(stdout, stderr io.Writer) *xContext {
+	return &xContext{
+		scope:      c.ctx.scope,
+		cook:       c,
+		stdout:     stdout,
+		stderr:     stderr,
+		continueAt: -1,
+		breakAt:    -1,
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package ast
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup start the command in a new process group so the command and every process it
+// started can be killed together.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	// a negative pid send the signal to every process in the group
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/cozees/cook/pkg/cook/token"
+	cookErrors "github.com/cozees/cook/pkg/errors"
 )
 
 type Statement interface {
@@ -19,13 +21,20 @@ type BlockStatement struct {
 	root  bool // if BlockStatement were use for Cook initial statement
 	plain bool // for Cook or Target we don't print {}
 	Stmts []Statement
+	End   int // offset of the closing brace, zero for Cook and Target block
 }
 
 func (bs *BlockStatement) Append(stmt Statement) { bs.Stmts = append(bs.Stmts, stmt) }
 
 func (bs *BlockStatement) Evaluate(ctx Context) (err error) {
 	for _, stmt := range bs.Stmts {
+		if ctx.Tracing() {
+			traceStatement(ctx, stmt)
+		}
 		if err = stmt.Evaluate(ctx); err != nil {
+			if n, ok := stmt.(interface{ Position() token.Position }); ok {
+				return cookErrors.NewDiagnostic(n.Position(), cookErrors.CodeRuntime, err)
+			}
 			return err
 		} else if ctx.ShouldBreak(false) {
 			break
@@ -34,6 +43,23 @@ func (bs *BlockStatement) Evaluate(ctx Context) (err error) {
 	return nil
 }
 
+// traceStatement trace statement which does not contain other statement, call expression is
+// traced when its arguments are evaluated instead.
+func traceStatement(ctx Context, stmt Statement) {
+	var base *Base
+	switch s := stmt.(type) {
+	case *AssignStatement:
+		base = s.Base
+	case *BreakContinueStatement:
+		base = s.Base
+	case *ReturnStatement:
+		base = s.Base
+	}
+	if base != nil {
+		ctx.Trace(base.Position(), strings.TrimSpace(stmt.String()))
+	}
+}
+
 // AssignStatement implement Node and handle all assign operation
 type AssignStatement struct {
 	*Base
@@ -54,6 +80,8 @@ func (as *AssignStatement) Evaluate(ctx Context) (err error) {
 	var mMerge *MergeMap
 	if ce, ok := as.Value.(*Call); ok {
 		ce.OutputResult = true
+	} else if pp, ok := as.Value.(*Pipe); ok {
+		pp.OutputResult = true
 	} else if mMerge, ok = as.Value.(*MergeMap); ok {
 		if as.Op != token.ADD_ASSIGN {
 			return errors.New("invalid operator use with merge map syntax, only += operator is allowed")
@@ -93,7 +121,7 @@ avoidEvaluate:
 		if err != nil {
 			return err
 		} else if v == nil {
-			return fmt.Errorf("variable %s does not exist", as.Ident.VariableName())
+			return cookErrors.Errorf(as.Ident.Position(), cookErrors.CodeUndefined, "variable %s does not exist", as.Ident.VariableName())
 		} else {
 			switch as.Op {
 			case token.ADD_ASSIGN:
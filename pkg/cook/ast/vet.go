@@ -0,0 +1,360 @@
+package ast
+
+import (
+	"sort"
+
+	"github.com/cozees/cook/pkg/cook/token"
+	cookErrors "github.com/cozees/cook/pkg/errors"
+	"github.com/cozees/cook/pkg/runtime/function"
+)
+
+// vetScope hold the variables of the top level block, a target or a function being checked.
+type vetScope struct {
+	// known is the variables assigned so far
+	known map[string]bool
+	// assigned is every variable assigned in the block, a variable which is never assigned may be
+	// given via argument or environment variable thus only variable in assigned is reported.
+	assigned map[string]bool
+	// labels of the enclosing for loops, an unlabeled loop is an empty string
+	loops []string
+}
+
+func newVetScope(known map[string]bool, bs *BlockStatement) *vetScope {
+	s := &vetScope{known: make(map[string]bool), assigned: make(map[string]bool)}
+	for name := range known {
+		s.known[name] = true
+	}
+	assignedIn(bs, s.assigned)
+	return s
+}
+
+// assignedIn add every variable assigned in the block and its nested block to names.
+func assignedIn(bs *BlockStatement, names map[string]bool) {
+	if bs == nil {
+		return
+	}
+	for _, stmt := range bs.Stmts {
+		switch s := stmt.(type) {
+		case *AssignStatement:
+			if id, ok := s.Ident.(*Ident); ok && s.Op == token.ASSIGN {
+				names[id.Name] = true
+			}
+		case *ForStatement:
+			assignedIn(s.Insts, names)
+		case *TryStatement:
+			assignedIn(s.Insts, names)
+			if s.Err != nil {
+				names[s.Err.Name] = true
+			}
+			assignedIn(s.Catch, names)
+		case *IfStatement:
+			for s != nil {
+				assignedIn(s.Insts, names)
+				if s.Else == nil {
+					break
+				} else if s.Else.IfStmt == nil {
+					assignedIn(s.Else.Insts, names)
+					break
+				}
+				s = s.Else.IfStmt
+			}
+		}
+	}
+}
+
+type vet struct {
+	cook  *cook
+	diags []*cookErrors.Diagnostic
+}
+
+// Vet report mistakes which otherwise only show up when the Cookfile is executed, that is calling
+// an undefined target or function, using a variable before it is assigned, break or continue
+// outside of a for loop, an unknown flag of a built-in function and a wrong number of arguments
+// given to a function declared in the Cookfile. The diagnostics are ordered by their position.
+func Vet(c Cook) []*cookErrors.Diagnostic {
+	v := &vet{cook: c.(*cook)}
+	v.block(v.cook.Insts, newVetScope(nil, v.cook.Insts))
+	globals := make(map[string]bool)
+	assignedIn(v.cook.Insts, globals)
+	targets := append(append([]*Target{}, v.cook.initializeTargets...), v.cook.finalizeTargets...)
+	for _, t := range append(targets, v.cook.Targets()...) {
+		for _, dep := range t.deps {
+			if v.cook.getTarget(dep.Name) == nil {
+				v.report(dep.Position(), cookErrors.CodeUndefined, "target %s depends on undefined target %s", t.name, dep.Name)
+			}
+		}
+		s := newVetScope(globals, t.Insts)
+		for _, p := range t.Params {
+			// default value can refer to the preceding arguments
+			v.expr(p.Default, s)
+			s.known[p.Name] = true
+		}
+		v.block(t.Insts, s)
+	}
+	for _, fn := range v.cook.Functions() {
+		v.function(fn, globals)
+	}
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		pi, pj := v.diags[i].Position, v.diags[j].Position
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		} else if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		return pi.Column < pj.Column
+	})
+	return v.diags
+}
+
+func (v *vet) report(pos token.Position, code cookErrors.Code, format string, args ...interface{}) *cookErrors.Diagnostic {
+	d := cookErrors.Errorf(pos, code, format, args...)
+	v.diags = append(v.diags, d)
+	return d
+}
+
+func (v *vet) function(fn *Function, known map[string]bool) {
+	s := newVetScope(known, fn.Insts)
+	for i, arg := range fn.Args {
+		// default value can refer to the preceding arguments
+		v.expr(fn.defaultOf(i), s)
+		s.known[arg.Name] = true
+	}
+	if fn.Lambda == token.LAMBDA {
+		v.expr(fn.X, s)
+	} else {
+		v.block(fn.Insts, s)
+	}
+}
+
+func (v *vet) block(bs *BlockStatement, s *vetScope) {
+	if bs == nil {
+		return
+	}
+	for _, stmt := range bs.Stmts {
+		switch st := stmt.(type) {
+		case *AssignStatement:
+			v.expr(st.Value, s)
+			if id, ok := st.Ident.(*Ident); ok && st.Op == token.ASSIGN {
+				s.known[id.Name] = true
+			} else {
+				v.expr(st.Ident, s)
+			}
+		case *ExprWrapperStatement:
+			v.expr(st.X, s)
+		case *ReturnStatement:
+			v.expr(st.X, s)
+		case *RaiseStatement:
+			v.expr(st.X, s)
+		case *ExportStatement:
+			if st.X != nil {
+				v.expr(st.X, s)
+				s.known[st.Name.Name] = true
+			} else {
+				v.expr(st.Name, s)
+			}
+		case *UnsetStatement:
+			for _, id := range st.Names {
+				delete(s.known, id.Name)
+			}
+		case *TryStatement:
+			v.block(st.Insts, s)
+			if st.Err != nil {
+				s.known[st.Err.Name] = true
+			}
+			v.block(st.Catch, s)
+		case *ForStatement:
+			if st.Oprnd != nil {
+				v.expr(st.Oprnd, s)
+			} else if st.Range != nil {
+				v.expr(st.Range, s)
+			}
+			for _, id := range []*Ident{st.I, st.Value} {
+				if id != nil {
+					s.known[id.Name] = true
+				}
+			}
+			s.loops = append(s.loops, st.Label)
+			v.block(st.Insts, s)
+			s.loops = s.loops[:len(s.loops)-1]
+		case *IfStatement:
+			for st != nil {
+				v.expr(st.Cond, s)
+				v.block(st.Insts, s)
+				if st.Else == nil {
+					break
+				} else if st.Else.IfStmt == nil {
+					v.block(st.Else.Insts, s)
+					break
+				}
+				st = st.Else.IfStmt
+			}
+		case *BreakContinueStatement:
+			if len(s.loops) == 0 {
+				v.report(st.Position(), cookErrors.CodeSyntax, "%s is not in a for loop", st.Op)
+			} else if st.Label != "" && !containString(s.loops, st.Label) {
+				v.report(st.Position(), cookErrors.CodeUndefined, "%s label %s is not defined", st.Op, st.Label)
+			}
+		}
+	}
+}
+
+func containString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *vet) expr(n Node, s *vetScope) {
+	switch x := n.(type) {
+	case *Ident:
+		if !s.known[x.Name] && s.assigned[x.Name] {
+			d := v.report(x.Position(), cookErrors.CodeUndefined, "variable %s is used before it is assigned", x.Name)
+			d.Severity = cookErrors.SeverityWarning
+			// report only the first use
+			s.known[x.Name] = true
+		}
+	case *Conditional:
+		v.expr(x.Cond, s)
+		v.expr(x.True, s)
+		v.expr(x.False, s)
+	case *Fallback:
+		// primary value is allowed to be undefined
+		v.expr(x.Default, s)
+	case *SizeOf:
+		v.expr(x.X, s)
+	case *IsType:
+		v.expr(x.X, s)
+	case *TypeCast:
+		v.expr(x.X, s)
+	case *Exit:
+		v.expr(x.ExitCode, s)
+	case *ArrayLiteral:
+		for _, val := range x.Values {
+			v.expr(val, s)
+		}
+	case *MapLiteral:
+		for i, key := range x.Keys {
+			v.expr(key, s)
+			v.expr(x.Values[i], s)
+		}
+	case *MergeMap:
+		v.expr(x.Value, s)
+	case *Delete:
+		v.expr(x.X, s)
+		for _, ix := range x.Indexes {
+			v.expr(ix, s)
+		}
+		if x.End != nil {
+			v.expr(x.End, s)
+		}
+	case *Index:
+		v.expr(x.X, s)
+		v.expr(x.Index, s)
+	case *SubValue:
+		v.expr(x.X, s)
+		v.expr(x.Range, s)
+	case *Interval:
+		v.expr(x.A, s)
+		v.expr(x.B, s)
+		if x.Step != nil {
+			v.expr(x.Step, s)
+		}
+	case *Exists:
+		// checking whether a variable exist is not a use of the variable
+		if x.Op == token.FD {
+			v.expr(x.X, s)
+		}
+	case *Call:
+		v.call(x, s)
+	case *NamedArg:
+		v.expr(x.X, s)
+	case *Pipe:
+		v.expr(x.X, s)
+		if x.Y != nil {
+			v.expr(x.Y, s)
+		}
+	case *ReadFrom:
+		v.expr(x.File, s)
+	case *RedirectTo:
+		v.expr(x.Caller, s)
+		for _, f := range x.Files {
+			if f != nil {
+				v.expr(f, s)
+			}
+		}
+	case *Paren:
+		v.expr(x.Inner, s)
+	case *Unary:
+		v.expr(x.X, s)
+	case *IncDec:
+		v.expr(x.X, s)
+	case *Binary:
+		v.expr(x.L, s)
+		v.expr(x.R, s)
+	case *Transformation:
+		v.expr(x.Ident, s)
+		if x.Mapper != nil {
+			v.expr(x.Mapper, s)
+		} else {
+			v.function(x.Fn, s.known)
+		}
+	case *FunctionLit:
+		v.function(x.Fn, s.known)
+	case *Spawn:
+		v.expr(x.X, s)
+	case *Wait:
+		if x.X != nil {
+			v.expr(x.X, s)
+		}
+	case *StringInterpolation:
+		for _, node := range x.nodes {
+			v.expr(node, s)
+		}
+	}
+}
+
+func (v *vet) call(c *Call, s *vetScope) {
+	var values []interface{}
+	var names []string
+	for _, opt := range c.Options {
+		v.expr(opt.X, s)
+	}
+	for _, arg := range c.Args {
+		if arg == nil {
+			// line continuation
+			continue
+		}
+		v.expr(arg, s)
+		if na, ok := arg.(*NamedArg); ok {
+			names = append(names, na.Name)
+		} else if bl, ok := arg.(*BasicLit); ok && bl.Kind == token.STRING {
+			values = append(values, bl.Lit)
+		} else {
+			values = append(values, nil)
+		}
+	}
+	switch {
+	case c.FuncLit != nil:
+		v.function(c.FuncLit, s.known)
+	case c.Kind != token.AT:
+	case s.known[c.Name] || s.assigned[c.Name]:
+		// a variable holding a function
+	case v.cook.getTarget(c.Name) != nil:
+		if err := v.cook.getTarget(c.Name).CheckArgs(len(values), names); err != nil {
+			v.report(c.Position(), cookErrors.CodeArgument, "%s", err)
+		}
+	case function.GetFunction(c.Name) != nil:
+		if err := function.GetFunction(c.Name).Flags().CheckArgs(values); err != nil {
+			v.report(c.Position(), cookErrors.CodeArgument, "@%s: %s", c.Name, err)
+		}
+	case v.cook.fns[c.Name] != nil:
+		if err := v.cook.fns[c.Name].CheckArgs(len(values), names); err != nil {
+			v.report(c.Position(), cookErrors.CodeArgument, "function %s: %s", c.Name, err)
+		}
+	default:
+		v.report(c.Position(), cookErrors.CodeUndefined, "target or function %s is not exist", c.Name)
+	}
+}
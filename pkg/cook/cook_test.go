@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/cozees/cook/pkg/cook/ast"
 	"github.com/cozees/cook/pkg/cook/parser"
 	"github.com/cozees/cook/pkg/cook/token"
+	cookErrors "github.com/cozees/cook/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -201,3 +205,786 @@ func TestExecuteState(t *testing.T) {
 		tc.verifier(t, c.Scope())
 	}
 }
+
+const dependencySrc = `
+ORDER = []
+
+generate:
+    ORDER += 'generate'
+
+lint: generate
+    ORDER += 'lint'
+
+build: generate lint
+    ORDER += 'build'
+
+test: build
+    ORDER += 'test'
+`
+
+func TestTargetDependency(t *testing.T) {
+	p := parser.NewParser()
+	c, err := p.ParseSrc(token.NewFile("sample", len(dependencySrc)), []byte(dependencySrc))
+	require.NoError(t, err)
+	require.NoError(t, c.ExecuteWithTarget(nil, "test", "lint"))
+	v, _, _ := c.Scope().GetVariable("ORDER")
+	assert.Equal(t, []interface{}{"generate", "lint", "build", "test"}, v)
+
+	require.Error(t, c.ExecuteWithTarget(nil, "deploy"))
+}
+
+const cyclicSrc = `
+generate: build
+    @print 'generate'
+
+build: lint
+    @print 'build'
+
+lint: generate
+    @print 'lint'
+`
+
+func TestTargetDependencyCycle(t *testing.T) {
+	p := parser.NewParser()
+	c, err := p.ParseSrc(token.NewFile("sample", len(cyclicSrc)), []byte(cyclicSrc))
+	require.NoError(t, err)
+	err = c.ExecuteWithTarget(nil, "build")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "build -> lint -> generate -> build")
+	assert.Contains(t, err.Error(), "target generate declared at")
+	assert.Contains(t, err.Error(), "depends on target build declared at")
+}
+
+const functionSrc = `
+greeting() {
+    return 'hello'
+}
+
+double(x) {
+    return x * 2
+}
+
+triple(x) => x * 3
+
+build:
+    A = @greeting
+    B = @double 3
+    C = @triple B
+`
+
+func TestDeclareFunction(t *testing.T) {
+	p := parser.NewParser()
+	c, err := p.ParseSrc(token.NewFile("sample", len(functionSrc)), []byte(functionSrc))
+	require.NoError(t, err)
+	require.NoError(t, c.ExecuteWithTarget(nil, "build"))
+	for name, expect := range map[string]interface{}{"A": "hello", "B": int64(6), "C": int64(18)} {
+		v, _, _ := c.Scope().GetVariable(name)
+		assert.Equal(t, expect, v, name)
+	}
+}
+
+const incrementalSrc = `
+RUN = false
+GENERATED = 0
+
+build: < 'main.go' > 'app'
+    RUN = true
+
+generate: > 'app'
+    GENERATED++
+`
+
+func TestIncrementalTarget(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "cook-incremental")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	p := parser.NewParser()
+	c, err := p.ParseSrc(token.NewFile("sample", len(incrementalSrc)), []byte(incrementalSrc))
+	require.NoError(t, err)
+	executed := func() bool {
+		require.NoError(t, c.ExecuteWithTarget(nil, "build"))
+		v, _, _ := c.Scope().GetVariable("RUN")
+		return v.(bool)
+	}
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, ioutil.WriteFile("main.go", []byte("package main"), 0644))
+	require.NoError(t, os.Chtimes("main.go", past, past))
+	// output is missing
+	assert.True(t, executed())
+	// output is newer than input
+	require.NoError(t, ioutil.WriteFile("app", []byte("binary"), 0644))
+	assert.False(t, executed())
+	// input is touched but its content is unchanged
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes("main.go", future, future))
+	assert.False(t, executed())
+	// input content is changed
+	require.NoError(t, ioutil.WriteFile("main.go", []byte("package app"), 0644))
+	require.NoError(t, os.Chtimes("main.go", future, future))
+	assert.True(t, executed())
+	// up to date but forced
+	c.SetOptions(&ast.Options{Force: true})
+	require.NoError(t, os.Chtimes("main.go", past, past))
+	assert.True(t, executed())
+	// target without input is executed even if its output exist
+	c.SetOptions(&ast.Options{})
+	require.FileExists(t, "app")
+	require.NoError(t, c.ExecuteWithTarget(nil, "generate"))
+	v, _, _ := c.Scope().GetVariable("GENERATED")
+	assert.Equal(t, int64(1), v)
+	// build state is recorded in the directory of the main Cookfile
+	require.NoError(t, os.Mkdir("sub", 0755))
+	require.NoError(t, os.Remove(".cookstate"))
+	c.SetOptions(&ast.Options{Dir: "sub"})
+	require.NoError(t, os.Chtimes("main.go", future, future))
+	assert.True(t, executed())
+	assert.FileExists(t, filepath.Join("sub", ".cookstate"))
+	assert.NoFileExists(t, ".cookstate")
+}
+
+const parallelSrc = `
+A = 0
+B = 0
+C = 0
+
+a:
+    SUM = 0
+    for i in [1..100] {
+        SUM += i
+    }
+    A = SUM
+
+b:
+    SUM = 0
+    for i in [1..50] {
+        SUM += i * 2
+    }
+    B = SUM
+
+c: a b
+    C = A + B
+
+version:
+    VERSION = '1.2.3'
+
+build: version
+    RESULT = 'building ' + VERSION
+
+release: build
+    RESULT += ' done'
+`
+
+func TestParallelTarget(t *testing.T) {
+	p := parser.NewParser()
+	c, err := p.ParseSrc(token.NewFile("sample", len(parallelSrc)), []byte(parallelSrc))
+	require.NoError(t, err)
+	c.SetOptions(&ast.Options{Jobs: 4})
+	require.NoError(t, c.ExecuteWithTarget(nil, "a", "b", "c"))
+	for name, expected := range map[string]int64{"A": 5050, "B": 2550, "C": 7600} {
+		v, _, _ := c.Scope().GetVariable(name)
+		assert.Equal(t, expected, v, name)
+	}
+	// variables declared by a target are declared globally once the target is finished
+	v, _, _ := c.Scope().GetVariable("SUM")
+	assert.Contains(t, []interface{}{int64(5050), int64(2550)}, v)
+	// a target read the variables declared by its dependencies
+	c, err = p.ParseSrc(token.NewFile("sample", len(parallelSrc)), []byte(parallelSrc))
+	require.NoError(t, err)
+	c.SetOptions(&ast.Options{Jobs: 2})
+	require.NoError(t, c.ExecuteWithTarget(nil, "release"))
+	v, _, _ = c.Scope().GetVariable("RESULT")
+	assert.Equal(t, "building 1.2.3 done", v)
+}
+
+const dryRunSrc = `
+FILE = 'out.txt'
+
+build:
+    NAME = @pbase 'dir/app.go'
+    #go 'build' '-o' NAME
+    @rm FILE
+    @print 'hello world' > FILE
+    #echo 'a' | #grep 'a'
+`
+
+func TestDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cook-dryrun")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	p := parser.NewParser()
+	c, err := p.ParseSrc(token.NewFile("sample", len(dryRunSrc)), []byte(dryRunSrc))
+	require.NoError(t, err)
+	c.SetOptions(&ast.Options{DryRun: true})
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	err = c.ExecuteWithTarget(nil, "build")
+	os.Stdout = stdout
+	w.Close()
+	require.NoError(t, err)
+	output, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, `build:
+#go build -o app.go
+@rm out.txt
+@print 'hello world' > out.txt
+#echo a | #grep a
+`, string(output))
+	_, err = os.Stat("out.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+const traceSrc = `trace
+
+double(x) {
+    return x * 2
+}
+
+build:
+    A = @double 3
+    B = @pbase 'dir/app.go'
+`
+
+func TestTrace(t *testing.T) {
+	p := parser.NewParser()
+	c, err := p.ParseSrc(token.NewFile("sample", len(traceSrc)), []byte(traceSrc))
+	require.NoError(t, err)
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = w
+	err = c.ExecuteWithTarget(nil, "build")
+	os.Stderr = stderr
+	w.Close()
+	require.NoError(t, err)
+	output, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	// remove elapsed time which is vary on each execution
+	lines := regexp.MustCompile(`(?m)^\+ \[[^\]]+\] `).ReplaceAllString(string(output), "")
+	assert.Equal(t, `sample:7:1 target build
+sample:8:5 A = @double 3
+sample:3:1 double(3)
+sample:4:5 return x * 2
+sample:9:5 B = @pbase 'dir/app.go'
+sample:9:9 @pbase dir/app.go
+`, lines)
+}
+
+const diagnosticSrc = `
+double(x) {
+    Y = x[3]
+    return Y
+}
+
+build:
+    @double [1]
+
+deploy:
+    @build
+`
+
+func TestDiagnostic(t *testing.T) {
+	p := parser.NewParser()
+	c, err := p.ParseSrc(token.NewFile("sample", len(diagnosticSrc)), []byte(diagnosticSrc))
+	require.NoError(t, err)
+	err = c.ExecuteWithTarget(nil, "deploy")
+	require.Error(t, err)
+	var d *cookErrors.Diagnostic
+	require.ErrorAs(t, err, &d)
+	assert.Equal(t, "sample:3:9: index 3 out of range, array length 1", d.Error())
+	assert.Equal(t, cookErrors.CodeIndex, d.Code)
+	assert.Equal(t, cookErrors.SeverityError, d.Severity)
+	require.Len(t, d.Stack, 3)
+	assert.Equal(t, "deploy", d.Stack[0].Name)
+	assert.Equal(t, "target", d.Stack[1].Kind)
+	assert.Equal(t, "build", d.Stack[1].Name)
+	assert.Equal(t, "function", d.Stack[2].Kind)
+	assert.Equal(t, "double", d.Stack[2].Name)
+	assert.Equal(t, 8, d.Stack[2].Position.Line)
+
+	_, err = p.ParseSrc(token.NewFile("sample", 12), []byte("build: x y\n\t@print 'hi\n"))
+	require.IsType(t, &cookErrors.CookError{}, err)
+	require.ErrorAs(t, (*err.(*cookErrors.CookError))[0], &d)
+	assert.Equal(t, cookErrors.CodeSyntax, d.Code)
+	assert.Equal(t, 2, d.Position.Line)
+}
+
+const vetSrc = `A = B + 1
+B = 2
+double(x) => x * 2
+build: test deploy
+    @double 1 2
+    @missing
+    @rm '-x' 'bin'
+    @rm '-r' 'bin'
+    if A > 1 {
+        break
+    }
+    for i in [1..3] {
+        continue:outer
+        @print "${C}"
+        C = i
+    }
+    D = X ?? 'none'
+
+test:
+    @double 2
+    @build
+`
+
+func TestVet(t *testing.T) {
+	c, err := parser.NewParser().ParseSrc(token.NewFile("sample", len(vetSrc)), []byte(vetSrc))
+	require.NoError(t, err)
+	expected := []struct {
+		line     int
+		code     cookErrors.Code
+		severity cookErrors.Severity
+	}{
+		{1, cookErrors.CodeUndefined, cookErrors.SeverityWarning},
+		{4, cookErrors.CodeUndefined, cookErrors.SeverityError},
+		{5, cookErrors.CodeArgument, cookErrors.SeverityError},
+		{6, cookErrors.CodeUndefined, cookErrors.SeverityError},
+		{7, cookErrors.CodeArgument, cookErrors.SeverityError},
+		{10, cookErrors.CodeSyntax, cookErrors.SeverityError},
+		{13, cookErrors.CodeUndefined, cookErrors.SeverityError},
+		{14, cookErrors.CodeUndefined, cookErrors.SeverityWarning},
+	}
+	diags := ast.Vet(c)
+	require.Len(t, diags, len(expected))
+	for i, d := range diags {
+		t.Logf("TestVet case #%d: %s", i+1, d)
+		assert.Equal(t, expected[i].line, d.Position.Line)
+		assert.Equal(t, expected[i].code, d.Code)
+		assert.Equal(t, expected[i].severity, d.Severity)
+	}
+}
+
+const replSrc = `
+double(x) => x * 2
+`
+
+func TestInterpreter(t *testing.T) {
+	c, err := parser.NewParser().ParseSrc(token.NewFile("sample", len(replSrc)), []byte(replSrc))
+	require.NoError(t, err)
+	in, err := ast.NewInterpreter(c, map[string]interface{}{"NAME": "cook"})
+	require.NoError(t, err)
+	cases := []struct {
+		src    string
+		result string
+		kind   reflect.Kind
+	}{
+		{"a = 2\n", "2", reflect.Int64},
+		{"a * 3 + 1\n", "7", reflect.Int64},
+		{"@double a\n", "4", reflect.Int64},
+		{"b = [a, 'x']\n", "[2, 'x']", reflect.Slice},
+		{"m = {'k': b[1]}\n", "{'k': 'x'}", reflect.Map},
+		{"c ?? NAME\n", "'cook'", reflect.String},
+		{"for i in [1..3] {\n    a += i\n}\n", "nil", reflect.Invalid},
+		{"a\n", "8", reflect.Int64},
+		{"triple(x) {\n    return x * 3\n}\n", "nil", reflect.Invalid},
+		{"@triple a\n", "24", reflect.Int64},
+	}
+	for i, tc := range cases {
+		t.Logf("TestInterpreter case #%d", i+1)
+		block, x, err := parser.ParseStatement(c, token.NewFile("repl", len(tc.src)), []byte(tc.src))
+		require.NoError(t, err)
+		var v interface{}
+		var kind reflect.Kind
+		if x != nil {
+			v, kind, err = in.EvaluateExpr(x)
+		} else {
+			v, kind, err = in.Evaluate(block)
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.kind, kind)
+		assert.Equal(t, tc.result, ast.FormatValue(v))
+	}
+	_, _, err = parser.ParseStatement(c, token.NewFile("repl", 4), []byte("1 +\n"))
+	assert.Error(t, err)
+}
+
+const argumentSrc = `
+add(a, b = a * 2, ...rest) {
+    for _, v in rest {
+        a += v
+    }
+    return a + b
+}
+join(sep = ',', ...items) => items
+`
+
+func TestFunctionArgument(t *testing.T) {
+	c, err := parser.NewParser().ParseSrc(token.NewFile("sample", len(argumentSrc)), []byte(argumentSrc))
+	require.NoError(t, err)
+	in, err := ast.NewInterpreter(c, nil)
+	require.NoError(t, err)
+	cases := []struct {
+		src    string
+		result string
+		err    string
+	}{
+		{src: "@add 1\n", result: "3"},
+		{src: "@add 1 5\n", result: "6"},
+		{src: "@add 1 b=5\n", result: "6"},
+		{src: "@add b=5 a=2\n", result: "7"},
+		{src: "@add 1 2 3 4\n", result: "10"},
+		{src: "@join\n", result: "[]"},
+		{src: "@join '-' 'x' 'y'\n", result: "['x', 'y']"},
+		{src: "@add\n", err: "missing argument a"},
+		{src: "@add 1 c=2\n", err: "unknown argument c"},
+		{src: "@add 1 a=2\n", err: "argument a is given more than once"},
+		{src: "@add 1 rest=2\n", err: "unknown argument rest"},
+	}
+	for i, tc := range cases {
+		t.Logf("TestFunctionArgument case #%d", i+1)
+		block, _, err := parser.ParseStatement(c, token.NewFile("sample", len(tc.src)), []byte(tc.src))
+		require.NoError(t, err)
+		v, _, err := in.Evaluate(block)
+		if tc.err != "" {
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		} else {
+			require.NoError(t, err)
+			assert.Equal(t, tc.result, ast.FormatValue(v))
+		}
+	}
+}
+
+const targetArgumentSrc = `
+RESULT = []
+
+deploy(env: string, replicas: integer = 1, ratio: float = replicas):
+    RESULT += [env, replicas, ratio]
+
+legacy:
+    FIRST = $1
+    COUNT = $0
+    RESULT += [FIRST, COUNT]
+
+all:
+    @deploy 'qa' ratio=2
+`
+
+func TestTargetArgument(t *testing.T) {
+	cases := []struct {
+		target string
+		args   map[string]string
+		result []interface{}
+		err    string
+	}{
+		{target: "deploy", args: map[string]string{"env": "prod", "replicas": "3"}, result: []interface{}{"prod", int64(3), float64(3)}},
+		{target: "deploy", args: map[string]string{"env": "dev", "ratio": "0.5"}, result: []interface{}{"dev", int64(1), 0.5}},
+		{target: "all", result: []interface{}{"qa", int64(1), float64(2)}},
+		{target: "deploy", err: "target deploy require argument env"},
+		{target: "deploy", args: map[string]string{"env": "dev", "replicas": "x"}, err: "argument replicas require integer, given 'x'"},
+		{target: "deploy", args: map[string]string{"env": "dev", "region": "eu"}, err: "target deploy does not have argument region"},
+		{target: "legacy", args: map[string]string{"env": "dev"}, err: "target legacy does not have argument env"},
+	}
+	for i, tc := range cases {
+		t.Logf("TestTargetArgument case #%d", i+1)
+		c, err := parser.NewParser().ParseSrc(token.NewFile("sample", len(targetArgumentSrc)), []byte(targetArgumentSrc))
+		require.NoError(t, err)
+		c.SetOptions(&ast.Options{TargetArgs: map[string]map[string]string{tc.target: tc.args}})
+		err = c.ExecuteWithTarget(nil, tc.target)
+		if tc.err != "" {
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		} else {
+			require.NoError(t, err)
+			v, _, _ := c.Scope().GetVariable("RESULT")
+			assert.Equal(t, tc.result, v)
+		}
+	}
+
+	c, err := parser.NewParser().ParseSrc(token.NewFile("sample", len(targetArgumentSrc)), []byte(targetArgumentSrc))
+	require.NoError(t, err)
+	in, err := ast.NewInterpreter(c, nil)
+	require.NoError(t, err)
+	src := "@legacy 'a' 'b'\n"
+	block, _, err := parser.ParseStatement(c, token.NewFile("sample", len(src)), []byte(src))
+	require.NoError(t, err)
+	_, _, err = in.Evaluate(block)
+	require.NoError(t, err)
+	v, _, _ := c.Scope().GetVariable("RESULT")
+	assert.Equal(t, []interface{}{"a", int64(2)}, v)
+}
+
+const closureSrc = `
+double(x) => x * 2
+
+counter() {
+    n = 0
+    return () {
+        n += 1
+        return n
+    }
+}
+
+multiplier(n) => (x) => x * n
+
+apply(f, x) {
+    r = @f x
+    return r
+}
+`
+
+func TestClosure(t *testing.T) {
+	c, err := parser.NewParser().ParseSrc(token.NewFile("sample", len(closureSrc)), []byte(closureSrc))
+	require.NoError(t, err)
+	in, err := ast.NewInterpreter(c, nil)
+	require.NoError(t, err)
+	cases := []struct {
+		src    string
+		result string
+		kind   reflect.Kind
+	}{
+		{"f = double\n", "double(x) => x * 2", reflect.Func},
+		{"@f 4\n", "8", reflect.Int64},
+		{"x ?? 'unset'\n", "'unset'", reflect.String},
+		{"@apply double 5\n", "10", reflect.Int64},
+		{"triple = @multiplier 3\n", "(x) => x * n", reflect.Func},
+		{"@triple 7\n", "21", reflect.Int64},
+		{"@apply ((x) => x + 100) 1\n", "101", reflect.Int64},
+		{"next = @counter\n", "() {\nn += 1\nreturn n\n}", reflect.Func},
+		{"@next\n", "1", reflect.Int64},
+		{"@next\n", "2", reflect.Int64},
+		{"k = 10\n", "10", reflect.Int64},
+		{"add = (x, y = k) => x + y\n", "(x, y = k) => x + y", reflect.Func},
+		{"@add 1\n", "11", reflect.Int64},
+		{"A = [1, 2, 3]\n", "[1, 2, 3]", reflect.Slice},
+		{"B = A(double)\nb = B[2]\n", "6", reflect.Int64},
+		{"C = A(triple)\nc = C[2]\n", "9", reflect.Int64},
+		{"D = A(i, v) => i + v\nd = D[2]\n", "5", reflect.Int64},
+		{"fs = [double, triple]\n", "[double(x) => x * 2, (x) => x * n]", reflect.Slice},
+		{"h = fs[1]\n", "(x) => x * n", reflect.Func},
+		{"@h 2\n", "6", reflect.Int64},
+	}
+	for i, tc := range cases {
+		t.Logf("TestClosure case #%d", i+1)
+		block, x, err := parser.ParseStatement(c, token.NewFile("sample", len(tc.src)), []byte(tc.src))
+		require.NoError(t, err)
+		var v interface{}
+		var kind reflect.Kind
+		if x != nil {
+			v, kind, err = in.EvaluateExpr(x)
+		} else {
+			v, kind, err = in.Evaluate(block)
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.kind, kind)
+		assert.Equal(t, tc.result, ast.FormatValue(v))
+	}
+}
+
+const tryCatchSrc = `
+RESULT = []
+
+check(x) {
+    if x > 2 {
+        raise 'too big'
+    }
+    return x
+}
+
+retry:
+    for i in [1..4] {
+        try {
+            a = @check i
+            RESULT += [a]
+        } catch e {
+            RESULT += [e['message'], e['code'], e['exit']]
+            break
+        }
+    }
+
+command:
+    try {
+        #sh '-c' 'exit 3'
+        RESULT += ['not reached']
+    } catch err {
+        RESULT += [err['exit'], err['code'], err['line']]
+    }
+
+nested:
+    try {
+        try {
+            raise {'message': 'custom', 'exit': 4}
+        } catch e {
+            RESULT += [e['message']]
+            raise e
+        }
+    } catch {
+        RESULT += ['outer']
+    }
+
+uncaught:
+    raise {'message': 'fatal', 'exit': 5}
+
+grouped:
+    try {
+        raise ('too' + ' late')
+    } catch e {
+        RESULT += [e['message']]
+    }
+`
+
+func TestTryCatch(t *testing.T) {
+	cases := []struct {
+		target string
+		result []interface{}
+		err    string
+		exit   int
+	}{
+		{target: "retry", result: []interface{}{int64(1), int64(2), "too big", "E0501", int64(1)}},
+		{target: "command", result: []interface{}{int64(3), "E0400", int64(24)}},
+		{target: "nested", result: []interface{}{"custom", "outer"}},
+		{target: "uncaught", result: []interface{}{}, err: "fatal", exit: 5},
+		{target: "grouped", result: []interface{}{"too late"}},
+	}
+	for i, tc := range cases {
+		t.Logf("TestTryCatch case #%d", i+1)
+		c, err := parser.NewParser().ParseSrc(token.NewFile("sample", len(tryCatchSrc)), []byte(tryCatchSrc))
+		require.NoError(t, err)
+		err = c.ExecuteWithTarget(nil, tc.target)
+		if tc.err != "" {
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+			assert.Equal(t, tc.exit, ast.ExitCode(err))
+		} else {
+			require.NoError(t, err)
+		}
+		v, _, _ := c.Scope().GetVariable("RESULT")
+		assert.Equal(t, tc.result, v)
+	}
+}
+
+const jobSrc = `
+RESULT = []
+
+slow(n) {
+    #sleep n
+    return n * 10
+}
+
+parallel:
+    jobs = []
+    delays = [0.2, 0.3, 0.1]
+    for i, d in delays {
+        j = spawn @slow d
+        jobs += [j]
+    }
+    RESULT = wait jobs
+
+result:
+    j = spawn ##sh '-c' 'echo out; exit 3'
+    r = wait j
+    RESULT = [r['code'], r['stdout']]
+
+failed:
+    spawn #sh '-c' 'exit 2'
+    try {
+        wait
+    } catch e {
+        RESULT = [e['exit']]
+    }
+
+server:
+    spawn #sleep 30
+    RESULT = ['leave']
+
+grouped:
+    j = spawn @slow 0.1
+    r = wait (j)
+    RESULT = [r]
+`
+
+func TestJob(t *testing.T) {
+	cases := []struct {
+		target string
+		result []interface{}
+	}{
+		{target: "parallel", result: []interface{}{float64(2), float64(3), float64(1)}},
+		{target: "result", result: []interface{}{int64(3), "out\n"}},
+		{target: "failed", result: []interface{}{int64(2)}},
+		{target: "server", result: []interface{}{"leave"}},
+		{target: "grouped", result: []interface{}{float64(1)}},
+	}
+	for i, tc := range cases {
+		t.Logf("TestJob case #%d", i+1)
+		c, err := parser.NewParser().ParseSrc(token.NewFile("sample", len(jobSrc)), []byte(jobSrc))
+		require.NoError(t, err)
+		start := time.Now()
+		require.NoError(t, c.ExecuteWithTarget(nil, tc.target))
+		// jobs run at the same time and a job still running is killed when the target end
+		assert.Less(t, time.Since(start), 5*time.Second)
+		v, _, _ := c.Scope().GetVariable("RESULT")
+		assert.Equal(t, tc.result, v)
+	}
+}
+
+const envSrc = `
+read:
+    RESULT = [COOK_TEST_HOME]
+
+exported:
+    export MODE = 'debug'
+    r1 = ##printenv 'MODE'
+    MODE = 'release'
+    r2 = ##printenv 'MODE'
+    LEVEL = 3
+    export LEVEL
+    r3 = ##printenv 'LEVEL'
+    RESULT = [r1['stdout'], r2['stdout'], r3['stdout']]
+
+removed:
+    export MODE = 'debug'
+    unset MODE COOK_TEST_HOME
+    r = ##printenv 'MODE'
+    m = MODE exists
+    h = COOK_TEST_HOME exists
+    RESULT = [r['code'], m, h]
+
+loadenv:
+    loaded = @dotenv ENVFILE
+    r = ##printenv 'COOK_TEST_APP'
+    RESULT = [loaded['COOK_TEST_GREETING'], r['stdout'], COOK_TEST_RAW]
+`
+
+func TestEnvironment(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, ioutil.WriteFile(envFile, []byte("# sample\nexport COOK_TEST_APP=cook # name\nCOOK_TEST_GREETING=\"hello ${COOK_TEST_APP}\"\nCOOK_TEST_RAW='$COOK_TEST_APP'\n"), 0644))
+	cases := []struct {
+		target string
+		result []interface{}
+	}{
+		{target: "read", result: []interface{}{"/home/cook"}},
+		{target: "exported", result: []interface{}{"debug\n", "release\n", "3\n"}},
+		{target: "removed", result: []interface{}{int64(1), false, false}},
+		{target: "loadenv", result: []interface{}{"hello cook", "cook\n", "$COOK_TEST_APP"}},
+	}
+	for i, tc := range cases {
+		t.Logf("TestEnvironment case #%d", i+1)
+		os.Setenv("COOK_TEST_HOME", "/home/cook")
+		c, err := parser.NewParser().ParseSrc(token.NewFile("sample", len(envSrc)), []byte(envSrc))
+		require.NoError(t, err)
+		require.NoError(t, c.ExecuteWithTarget(map[string]interface{}{"ENVFILE": envFile}, tc.target))
+		v, _, _ := c.Scope().GetVariable("RESULT")
+		assert.Equal(t, tc.result, v)
+	}
+	for _, name := range []string{"COOK_TEST_HOME", "COOK_TEST_APP", "COOK_TEST_GREETING", "COOK_TEST_RAW"} {
+		os.Unsetenv(name)
+	}
+}
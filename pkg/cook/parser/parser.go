@@ -5,10 +5,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cozees/cook/pkg/cook/ast"
 	"github.com/cozees/cook/pkg/cook/token"
 	cookErrors "github.com/cozees/cook/pkg/errors"
+	"github.com/cozees/cook/pkg/runtime/glob"
 )
 
 type Parser interface {
@@ -41,15 +43,71 @@ type parser struct {
 	nLit  string
 
 	errs *cookErrors.CookError
+
+	// the most recent comment block, the file and the line where it end, use as documentation of a
+	// target or a function declared on the next line of the same file
+	doc     []string
+	docEnd  int
+	docFile *token.File
+
+	// raw keep the source as written, directives are recorded and included files are not parsed,
+	// glob pattern in array literal is not expanded either. It is used by the formatter.
+	raw        bool
+	directives []*ast.BasicLit
+
+	// header is true while parsing the condition of an if statement or the operand of a for loop,
+	// { after a parenthesis start the block of the statement instead of a function literal
+	header bool
 }
 
 func (p *parser) curPos() token.Position { return p.tfile.Position(p.cOffs) }
 
+// Format parse the Cookfile source and return it in the canonical layout. The included files are
+// not parsed and glob pattern in array literal is kept as written thus the result can safely
+// replace the original source.
+func Format(file *token.File, src []byte, maxLength int) ([]byte, error) {
+	p := &parser{parsed: make(map[string]*token.File), pending: make(map[string]*token.File), raw: true}
+	cook, err := p.ParseSrc(file, src)
+	if err != nil {
+		return nil, err
+	}
+	return ast.Format(cook, file, src, p.directives, maxLength), nil
+}
+
+// ParseStatement parse the source as top level statements of an existing Cook, a function or a
+// target declared in the source is added to the Cook. If the source is not a statement, it is
+// parsed as a single expression instead and the expression is return in place of the block.
+func ParseStatement(cook ast.Cook, file *token.File, src []byte) (*ast.BlockStatement, ast.Node, error) {
+	p := &parser{parsed: make(map[string]*token.File), pending: make(map[string]*token.File)}
+	if err := p.init(file, src); err != nil {
+		return nil, nil, err
+	}
+	p.cook, p.block = cook, &ast.BlockStatement{}
+	block := p.block
+	_, err := p.parse()
+	if err == nil {
+		return block, nil, nil
+	}
+	// the statement error take priority over the expression error
+	xp := &parser{cook: cook}
+	if xp.init(token.NewFile(file.Name(), len(src)), src) == nil {
+		if x := xp.parseBinaryExpr(false, token.LowestPrec+1); x != nil && xp.errs == nil &&
+			(xp.cTok == token.LF || xp.cTok == token.EOF) {
+			return nil, x, nil
+		}
+	}
+	return nil, nil, err
+}
+
 func (p *parser) errorHandler(pos token.Position, msg string, args ...interface{}) {
+	p.errorCode(pos, cookErrors.CodeSyntax, msg, args...)
+}
+
+func (p *parser) errorCode(pos token.Position, code cookErrors.Code, msg string, args ...interface{}) {
 	if p.errs == nil {
 		p.errs = &cookErrors.CookError{}
 	}
-	p.errs.StackError(fmt.Errorf(pos.String()+" "+msg, args...))
+	p.errs.StackError(cookErrors.Errorf(pos, code, msg, args...))
 	// when encounter error immedate ignore everything until new statement
 	for {
 		p.next()
@@ -61,7 +119,7 @@ func (p *parser) errorHandler(pos token.Position, msg string, args ...interface{
 				// index assigned statement.
 				return
 			}
-		case token.FOR, token.IF, token.BREAK, token.CONTINUE, token.RETURN, token.EOF, token.COMMENT:
+		case token.FOR, token.IF, token.TRY, token.RAISE, token.SPAWN, token.WAIT, token.EXPORT, token.UNSET, token.BREAK, token.CONTINUE, token.RETURN, token.EOF, token.COMMENT:
 			return
 		}
 	}
@@ -80,6 +138,7 @@ func (p *parser) expect(require token.Token) (offs int) {
 
 func (p *parser) init(file *token.File, src []byte) (err error) {
 	p.tfile = file
+	p.doc, p.docEnd, p.docFile = p.doc[:0], 0, nil
 	if p.s, err = NewScannerSrc(file, src, p.errorHandler); err == nil {
 		p.s.skipLineFeed = true
 		p.cOffs, p.cTok, p.cLit = -1, 0, ""
@@ -89,10 +148,61 @@ func (p *parser) init(file *token.File, src []byte) (err error) {
 }
 
 func (p *parser) next() {
+	prevOffs, prevTok := p.cOffs, p.cTok
 	p.cOffs, p.cTok, p.cLit = p.nOffs, p.nTok, p.nLit
 	if p.nTok != token.EOF {
 		p.nOffs, p.nTok, p.nLit = p.s.Scan()
 	}
+	if p.cTok.IsContextual() && !p.isStatementKeyword(prevOffs, prevTok) {
+		p.cTok = token.IDENT
+	}
+}
+
+// isStatementKeyword return true if the contextual keyword at the current token, which follow
+// token prevTok at prevOffs, begin a statement. The keyword followed by : or an assignment is the
+// name of a target or a variable instead, so is the keyword which begin a declaration of a target
+// or a function.
+func (p *parser) isStatementKeyword(prevOffs int, prevTok token.Token) bool {
+	switch {
+	case p.nTok == token.COLON, p.nTok == token.INC, p.nTok == token.DEC,
+		token.ADD_ASSIGN <= p.nTok && p.nTok <= token.REM_ASSIGN,
+		token.AND_ASSIGN <= p.nTok && p.nTok <= token.ASSIGN:
+		return false
+	}
+	switch {
+	case prevTok == token.ILLEGAL, prevTok == token.LF, prevTok == token.LBRACE, prevTok == token.RBRACE,
+		prevTok == token.COMMENT:
+		return p.nTok != token.LPAREN || !p.isDeclaration()
+	case prevTok == token.COLON:
+		// the first statement of a target is placed on the next line, the same line is its dependencies
+		return p.tfile.Position(prevOffs).Line != p.curPos().Line && (p.nTok != token.LPAREN || !p.isDeclaration())
+	case token.ADD_ASSIGN <= prevTok && prevTok <= token.REM_ASSIGN,
+		token.AND_ASSIGN <= prevTok && prevTok <= token.ASSIGN:
+		// the value of an assignment can also be a job or the result of a job
+		return p.cTok == token.SPAWN || p.cTok == token.WAIT
+	}
+	return false
+}
+
+// isDeclaration return true if the parenthesis at the next token is closed by ) followed by :, {
+// or => thus it declare the parameters of a target or the arguments of a function, e.g. wait(a) => a
+// rather than a parenthesized expression such as wait (j).
+func (p *parser) isDeclaration() bool {
+	s := *p.s
+	s.errorHandler = func(token.Position, string, ...interface{}) {}
+	for depth := 1; ; {
+		switch _, tok, _ := s.Scan(); tok {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			if depth--; depth == 0 {
+				_, tok, _ = s.Scan()
+				return tok == token.COLON || tok == token.LBRACE || tok == token.LAMBDA
+			}
+		case token.EOF:
+			return false
+		}
+	}
 }
 
 func (p *parser) Parse(file string) (ast.Cook, error) {
@@ -125,6 +235,15 @@ nextFile:
 		if p.cTok == token.INCLUDE {
 			p.parseIncludeDirective()
 			continue
+		} else if p.cTok == token.TRACE {
+			if p.raw {
+				p.directives = append(p.directives, &ast.BasicLit{Base: &ast.Base{Offset: p.cOffs, File: p.tfile}, Lit: "trace", Kind: token.TRACE})
+			}
+			p.cook.EnableTrace()
+			if p.next(); p.cTok == token.LF {
+				p.next()
+			}
+			continue
 		}
 		break
 	}
@@ -132,12 +251,26 @@ nextFile:
 		switch p.cTok {
 		case token.INCLUDE:
 			p.errorHandler(p.curPos(), "include directive must place at the very top of the file.")
+		case token.TRACE:
+			p.errorHandler(p.curPos(), "trace directive must place at the very top of the file.")
 		case token.IDENT:
 			p.parseIdentifier(true)
 		case token.FOR:
 			p.parseForLoop(false)
 		case token.IF:
 			p.parseIf(false, nil)
+		case token.TRY:
+			p.parseTry(false)
+		case token.RAISE:
+			p.parseRaise()
+		case token.SPAWN:
+			p.parseSpawn(false)
+		case token.WAIT:
+			p.parseWait()
+		case token.EXPORT:
+			p.parseExport()
+		case token.UNSET:
+			p.parseUnset()
 		case token.AT, token.HASH:
 			p.parseCallReference(false, nil)
 		case token.EXIT:
@@ -149,9 +282,8 @@ nextFile:
 			}
 			p.expect(token.LF)
 		case token.COMMENT:
+			p.addDoc()
 			p.next()
-			// eat the comment for now.
-			// TODO: add comment to file which help when formatting the code
 		default:
 			p.errorHandler(p.curPos(), "invalid token %s", p.cTok)
 		}
@@ -182,7 +314,34 @@ nextFile:
 	}
 }
 
+// addDoc record the current comment, consecutive comment lines form a single block.
+func (p *parser) addDoc() {
+	start := p.curPos().Line
+	if p.docFile != p.tfile || start != p.docEnd+1 {
+		p.doc = p.doc[:0]
+	}
+	p.docEnd, p.docFile = p.tfile.Position(p.cOffs+len(p.cLit)-1).Line, p.tfile
+	text := p.cLit
+	if strings.HasPrefix(text, "/*") {
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+			p.doc = append(p.doc, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*")))
+		}
+	} else {
+		p.doc = append(p.doc, strings.TrimSpace(strings.TrimPrefix(text, "//")))
+	}
+}
+
+// docOf return the comment block which end right above the given offset.
+func (p *parser) docOf(offs int) string {
+	if len(p.doc) > 0 && p.docFile == p.tfile && p.docEnd+1 == p.tfile.Position(offs).Line {
+		return strings.Join(p.doc, "\n")
+	}
+	return ""
+}
+
 func (p *parser) parseIncludeDirective() {
+	offs := p.cOffs
 	p.next()
 	if p.cTok == token.STRING {
 		_, ok1 := p.parsed[p.cLit]
@@ -191,21 +350,25 @@ func (p *parser) parseIncludeDirective() {
 			// file have already be parsed, nothing to do here.
 			return
 		}
-		file := p.cLit
+		file := &ast.BasicLit{Base: &ast.Base{Offset: offs, File: p.tfile}, Lit: p.cLit, Kind: token.STRING, Mark: p.s.src[p.cOffs-1]}
 		p.next()
 		if p.expect(token.LF) == -1 {
 			return
+		} else if p.raw {
+			p.directives = append(p.directives, file)
+			return
 		}
 		// new file
-		ifile := filepath.Join(filepath.Dir(p.s.file.Name()), file)
+		ifile := filepath.Join(filepath.Dir(p.s.file.Name()), file.Lit)
 		if stat, err := os.Stat(ifile); err != nil {
 			if os.IsNotExist(err) {
-				p.errorHandler(p.curPos(), "included file %s not found", ifile)
+				p.errorCode(p.curPos(), cookErrors.CodeInclude, "included file %s not found", ifile)
 			} else {
-				p.errorHandler(p.curPos(), "unable to read included file %s ", ifile)
+				p.errorCode(p.curPos(), cookErrors.CodeInclude, "unable to read included file %s ", ifile)
 			}
 		} else {
 			p.pending[p.cLit] = token.NewFile(ifile, int(stat.Size()))
+			p.cook.AddInclude(ifile)
 		}
 	} else {
 		p.errorHandler(p.curPos(), "include directive expected string")
@@ -215,10 +378,31 @@ func (p *parser) parseIncludeDirective() {
 func (p *parser) parseIdentifier(head bool) {
 	switch p.nTok {
 	case token.COLON:
-		p.parseTarget()
+		offs, name := p.cOffs, p.cLit
+		p.next()
+		p.parseTarget(offs, name, nil)
 	case token.LPAREN:
-		// function declaration or calling a function
-		p.parseDeclareFunction(false)
+		// function declaration or target declaration with arguments
+		offs, name := p.cOffs, p.cLit
+		p.next()
+		p.next()
+		params, variadic, ok := p.parseDeclareArgument()
+		if !ok || p.expect(token.RPAREN) == -1 {
+			return
+		} else if p.cTok == token.COLON {
+			if variadic {
+				p.errorCode(params[len(params)-1].Position(), cookErrors.CodeDeclare, "target %s cannot declare variadic argument", name)
+				return
+			}
+			p.parseTarget(offs, name, params)
+		} else if fn := p.parseFunctionBody(name, params, variadic); fn != nil {
+			fn.Base = &ast.Base{File: p.tfile, Offset: offs}
+			fn.Doc = p.docOf(offs)
+			p.cook.AddFunction(fn)
+			if p.cTok == token.LF {
+				p.next()
+			}
+		}
 	case token.LBRACK:
 		// index expression
 		if x := p.parseIndexExpression(); x != nil {
@@ -236,8 +420,9 @@ func (p *parser) parseIdentifier(head bool) {
 		p.next()
 		p.block.Append(&ast.ExprWrapperStatement{
 			X: &ast.IncDec{
-				Op: p.cTok,
-				X:  &ast.Ident{Base: &ast.Base{Offset: offs, File: p.tfile}, Name: lit},
+				Base: &ast.Base{Offset: offs, File: p.tfile},
+				Op:   p.cTok,
+				X:    &ast.Ident{Base: &ast.Base{Offset: offs, File: p.tfile}, Name: lit},
 			},
 		})
 		p.next()
@@ -251,15 +436,57 @@ func (p *parser) parseIdentifier(head bool) {
 	}
 }
 
-func (p *parser) parseTarget() {
-	offs, name := p.cOffs, p.cLit
-	p.next()
-	if t, err := p.cook.AddTarget(&ast.Base{File: p.tfile, Offset: offs}, name); err != nil {
-		p.errorHandler(p.curPos(), err.Error())
-	} else if p.next(); name == "all" && p.cTok == token.MUL {
+// parseTarget parse the target declaration from the colon after its name or its arguments.
+func (p *parser) parseTarget(offs int, name string, params []*ast.Param) {
+	t, err := p.cook.AddTarget(&ast.Base{File: p.tfile, Offset: offs}, name)
+	if err != nil {
+		p.errorCode(p.curPos(), cookErrors.CodeDeclare, "%s", err)
+		return
+	}
+	t.Doc = p.docOf(offs)
+	for _, param := range params {
+		if err = t.AddParam(param); err != nil {
+			p.errorCode(param.Position(), cookErrors.CodeDeclare, "%s", err)
+			return
+		}
+	}
+	if p.next(); name == "all" && p.cTok == token.MUL {
 		t.SetCallAll()
 		p.next()
 	} else {
+		// any identifier on the same line as the target name is a dependency, e.g. build: generate lint
+		line := p.tfile.Position(offs).Line
+		for p.cTok == token.IDENT && p.curPos().Line == line {
+			if err := t.AddDependency(&ast.Base{File: p.tfile, Offset: p.cOffs}, p.cLit); err != nil {
+				p.errorCode(p.curPos(), cookErrors.CodeDeclare, "%s", err)
+				return
+			}
+			p.next()
+		}
+		// input files follow < and output files follow >, e.g. build: < 'main.go' > 'app'
+		for (p.cTok == token.LSS || p.cTok == token.GTR) && p.curPos().Line == line {
+			isInput := p.cTok == token.LSS
+			p.next()
+			for p.cTok != token.LF && p.cTok != token.EOF && p.cTok != token.LSS && p.cTok != token.GTR {
+				x, _ := p.parseOperand()
+				if x == nil {
+					return
+				}
+				var err error
+				if isInput {
+					err = t.AddInput(x)
+				} else {
+					err = t.AddOutput(x)
+				}
+				if err != nil {
+					p.errorCode(x.Position(), cookErrors.CodeDeclare, "%s", err)
+					return
+				}
+			}
+		}
+		if p.cTok == token.LF {
+			p.next()
+		}
 		p.block = t.Insts
 	}
 }
@@ -282,6 +509,9 @@ func (p *parser) parseAssignStatement(settableNode ast.SettableNode) {
 		if p.nTok == token.AT || p.nTok == token.HASH {
 			p.next()
 			assignStmt.Value = p.parseCallReference(true, nil)
+		} else if p.nTok == token.SPAWN {
+			p.next()
+			assignStmt.Value = p.parseSpawn(true)
 		} else {
 			assignStmt.Value = p.parseBinaryExpr(false, token.LowestPrec+1)
 		}
@@ -395,6 +625,12 @@ func (p *parser) parseUnaryExpr() (x ast.Node) {
 			Base: &ast.Base{Offset: offs, File: p.tfile},
 			X:    opr,
 		}
+	case token.WAIT:
+		w := &ast.Wait{Base: &ast.Base{Offset: p.cOffs, File: p.tfile}}
+		if p.next(); p.cTok != token.LF && p.cTok != token.EOF {
+			w.X, _ = p.parseOperand()
+		}
+		x = w
 	case token.VAR:
 		offs := p.cOffs
 		p.next()
@@ -468,6 +704,10 @@ func (p *parser) parseOperand() (x ast.Node, kind token.Token) {
 	case token.STRING_ITP:
 		x, kind = p.parseStringInterpolation(), token.STRING
 	case token.LPAREN:
+		if p.isFunctionLit() {
+			x, kind = p.parseFunctionLit(), token.LAMBDA
+			break
+		}
 		lparen := p.cOffs
 		inx := p.parseBinaryExpr(false, token.LowestPrec+1) // types may be parenthesized: (some type)
 		if p.expect(token.RPAREN) == -1 {
@@ -519,6 +759,7 @@ func (p *parser) parseStringInterpolation() ast.Node {
 
 func (p *parser) parseMapLiteral() ast.Node {
 	offs := p.s.offset
+	line := p.curPos().Line
 	p.next()
 	var keys []ast.Node
 	var values []ast.Node
@@ -546,8 +787,9 @@ func (p *parser) parseMapLiteral() ast.Node {
 			}
 		}
 	}
+	multiline := line != p.curPos().Line
 	if p.expect(token.RBRACE) != -1 {
-		return &ast.MapLiteral{Base: &ast.Base{Offset: offs, File: p.tfile}, Keys: keys, Values: values}
+		return &ast.MapLiteral{Base: &ast.Base{Offset: offs, File: p.tfile}, Keys: keys, Values: values, Multiline: multiline}
 	} else {
 		return nil
 	}
@@ -555,11 +797,12 @@ func (p *parser) parseMapLiteral() ast.Node {
 
 func (p *parser) parserArrayLiteral() ast.Node {
 	offs := p.s.offset
+	line := p.curPos().Line
 	p.next()
 	var values []ast.Node
 	if p.cTok != token.RBRACK {
 		x, tok := p.parseOperand()
-		if isGlob, nodes := parseArrayFile(x, tok); isGlob {
+		if isGlob, nodes := parseArrayFile(x, tok); isGlob && !p.raw {
 			values = append(values, nodes...)
 		} else {
 			values = append(values, x)
@@ -578,15 +821,19 @@ loop:
 				break loop
 			}
 			y, tok := p.parseOperand()
-			if isGlob, nodes := parseArrayFile(y, tok); isGlob {
+			if isGlob, nodes := parseArrayFile(y, tok); isGlob && !p.raw {
 				values = append(values, nodes...)
 			} else {
 				values = append(values, y)
 			}
+		default:
+			p.errorHandler(p.curPos(), "expect %s or %s but got %s", token.COMMA, token.RBRACK, p.cTok)
+			return nil
 		}
 	}
+	multiline := line != p.curPos().Line
 	if p.expect(token.RBRACK) != -1 {
-		return &ast.ArrayLiteral{Base: &ast.Base{Offset: offs, File: p.tfile}, Values: values}
+		return &ast.ArrayLiteral{Base: &ast.Base{Offset: offs, File: p.tfile}, Values: values, Multiline: multiline}
 	} else {
 		return nil
 	}
@@ -624,7 +871,9 @@ func (p *parser) parseForLoop(inForLoop bool) {
 				return
 			}
 			var tok token.Token
+			p.header = true
 			oprd, tok = p.parseOperand()
+			p.header = false
 			switch tok {
 			case token.INTEGER, token.FLOAT, token.BOOLEAN:
 				p.errorHandler(oprd.Position(), "for loop can iterate through %s", tok)
@@ -704,7 +953,9 @@ func (p *parser) parseIf(inForLoop bool, elstmt *ast.ElseStatement) {
 	// 	p.next()
 	// default:
 
+	p.header = true
 	cond := p.parseBinaryExpr(false, token.LowestPrec+1)
+	p.header = false
 	blcOffs := p.cOffs
 	if p.expect(token.LBRACE) == -1 {
 		return
@@ -746,6 +997,130 @@ func (p *parser) parseIf(inForLoop bool, elstmt *ast.ElseStatement) {
 	}
 }
 
+func (p *parser) parseTry(inForLoop bool) {
+	offs := p.cOffs
+	p.next()
+	blcOffs := p.cOffs
+	if p.expect(token.LBRACE) == -1 {
+		return
+	}
+	ts := &ast.TryStatement{
+		Base:  &ast.Base{Offset: offs, File: p.tfile},
+		Insts: &ast.BlockStatement{Base: &ast.Base{Offset: blcOffs, File: p.tfile}},
+	}
+	if !p.parseBlock(inForLoop, ts.Insts) || p.expect(token.CATCH) == -1 {
+		return
+	}
+	if p.cTok == token.IDENT {
+		ts.Err = &ast.Ident{Base: &ast.Base{Offset: p.cOffs, File: p.tfile}, Name: p.cLit}
+		p.next()
+	}
+	blcOffs = p.cOffs
+	if p.expect(token.LBRACE) == -1 {
+		return
+	}
+	ts.Catch = &ast.BlockStatement{Base: &ast.Base{Offset: blcOffs, File: p.tfile}}
+	if p.parseBlock(inForLoop, ts.Catch) {
+		p.block.Append(ts)
+	}
+}
+
+func (p *parser) parseRaise() {
+	offs := p.cOffs
+	if x := p.parseBinaryExpr(false, token.LowestPrec+1); x != nil {
+		p.block.Append(&ast.RaiseStatement{Base: &ast.Base{Offset: offs, File: p.tfile}, X: x})
+	}
+	if p.cTok == token.LF {
+		p.next()
+	}
+}
+
+// parseSpawn parse spawn followed by a call, the spawn statement is appended to the current block
+// unless the spawn is assigned to a variable.
+func (p *parser) parseSpawn(assign bool) ast.Node {
+	offs := p.cOffs
+	p.next()
+	if p.cTok != token.AT && p.cTok != token.HASH {
+		p.errorHandler(p.curPos(), "spawn require a call but got %s", p.cTok)
+		return nil
+	}
+	x := p.parseCallReference(true, nil)
+	if x == nil {
+		return nil
+	}
+	node := &ast.Spawn{Base: &ast.Base{Offset: offs, File: p.tfile}, X: x}
+	if !assign {
+		p.block.Append(&ast.ExprWrapperStatement{X: node})
+	}
+	return node
+}
+
+// parseWait parse wait statement which is not assigned to any variable.
+func (p *parser) parseWait() {
+	if x := p.parseUnaryExpr(); x != nil {
+		p.block.Append(&ast.ExprWrapperStatement{X: x})
+	}
+	if p.cTok == token.LF {
+		p.next()
+	}
+}
+
+// parseExport parse export statement which either assign a value to the variable and export it or
+// export an existing variable.
+func (p *parser) parseExport() {
+	offs := p.cOffs
+	p.next()
+	if p.cTok != token.IDENT {
+		p.errorHandler(p.curPos(), "export require a variable name but got %s", p.cTok)
+		return
+	}
+	es := &ast.ExportStatement{
+		Base: &ast.Base{Offset: offs, File: p.tfile},
+		Name: &ast.Ident{Base: &ast.Base{Offset: p.cOffs, File: p.tfile}, Name: p.cLit},
+	}
+	switch p.nTok {
+	case token.ASSIGN:
+		p.next()
+		if p.nTok == token.AT || p.nTok == token.HASH {
+			p.next()
+			es.X = p.parseCallReference(true, nil)
+		} else {
+			es.X = p.parseBinaryExpr(false, token.LowestPrec+1)
+		}
+		if es.X == nil {
+			return
+		}
+	case token.LF, token.EOF:
+		p.next()
+	default:
+		p.errorHandler(p.curPos(), "expect %s or %s but got %s", token.ASSIGN, token.LF, p.nTok)
+		return
+	}
+	if p.cTok == token.LF {
+		p.next()
+	}
+	p.block.Append(es)
+}
+
+// parseUnset parse unset statement followed by one or more variable names.
+func (p *parser) parseUnset() {
+	us := &ast.UnsetStatement{Base: &ast.Base{Offset: p.cOffs, File: p.tfile}}
+	for p.next(); p.cTok == token.IDENT; p.next() {
+		us.Names = append(us.Names, &ast.Ident{Base: &ast.Base{Offset: p.cOffs, File: p.tfile}, Name: p.cLit})
+	}
+	if len(us.Names) == 0 {
+		p.errorHandler(p.curPos(), "unset require a variable name but got %s", p.cTok)
+		return
+	} else if p.cTok != token.LF && p.cTok != token.EOF {
+		p.errorHandler(p.curPos(), "expect variable name but got %s", p.cTok)
+		return
+	}
+	if p.cTok == token.LF {
+		p.next()
+	}
+	p.block.Append(us)
+}
+
 func (p *parser) parseBlock(inForLoop bool, block *ast.BlockStatement) bool {
 	prevBlock := p.block
 	p.block = block
@@ -758,8 +1133,9 @@ func (p *parser) parseBlock(inForLoop bool, block *ast.BlockStatement) bool {
 				p.next()
 				p.block.Append(&ast.ExprWrapperStatement{
 					X: &ast.IncDec{
-						Op: p.cTok,
-						X:  &ast.Ident{Base: &ast.Base{Offset: offs, File: p.tfile}, Name: lit},
+						Base: &ast.Base{Offset: offs, File: p.tfile},
+						Op:   p.cTok,
+						X:    &ast.Ident{Base: &ast.Base{Offset: offs, File: p.tfile}, Name: lit},
 					},
 				})
 				p.next()
@@ -781,6 +1157,18 @@ func (p *parser) parseBlock(inForLoop bool, block *ast.BlockStatement) bool {
 			p.parseForLoop(inForLoop)
 		case token.IF:
 			p.parseIf(inForLoop, nil)
+		case token.TRY:
+			p.parseTry(inForLoop)
+		case token.RAISE:
+			p.parseRaise()
+		case token.SPAWN:
+			p.parseSpawn(false)
+		case token.WAIT:
+			p.parseWait()
+		case token.EXPORT:
+			p.parseExport()
+		case token.UNSET:
+			p.parseUnset()
 		case token.EXIT:
 			// parse exit
 			offs := p.cOffs
@@ -792,10 +1180,14 @@ func (p *parser) parseBlock(inForLoop bool, block *ast.BlockStatement) bool {
 			p.expect(token.LF)
 		case token.RETURN:
 			// parse return
+			offs := p.cOffs
 			p.block.Append(&ast.ReturnStatement{
-				Base: &ast.Base{Offset: p.cOffs, File: p.tfile},
+				Base: &ast.Base{Offset: offs, File: p.tfile},
 				X:    p.parseBinaryExpr(false, token.LowestPrec+1),
 			})
+			if p.cTok == token.LF {
+				p.next()
+			}
 		case token.BREAK, token.CONTINUE:
 			offs, label, op := p.cOffs, "", p.cTok
 			p.next()
@@ -819,9 +1211,14 @@ func (p *parser) parseBlock(inForLoop bool, block *ast.BlockStatement) bool {
 			p.next()
 			// eat comment for now
 			// TODO: add comment to token file
+		default:
+			p.errorHandler(p.curPos(), "invalid token %s", p.cTok)
 		}
 	}
 	endBlock := p.cTok == token.RBRACE
+	if endBlock {
+		block.End = p.cOffs
+	}
 	p.next()
 	if p.cTok == token.LF {
 		p.next()
@@ -950,25 +1347,39 @@ func (p *parser) parseTransformation() ast.Node {
 	if p.expect(token.LPAREN) == -1 {
 		return nil
 	}
-	if fn := p.parseDeclareFunction(true); fn != nil {
-		return &ast.Transformation{
-			Base:  &ast.Base{Offset: ftOffs, File: p.tfile},
-			Ident: &ast.Ident{Base: &ast.Base{Offset: ioffs, File: p.tfile}, Name: ilit},
-			Fn:    fn,
-		}
+	params, variadic, ok := p.parseDeclareArgument()
+	if !ok || p.expect(token.RPAREN) == -1 {
+		return nil
 	}
-	return nil
+	t := &ast.Transformation{
+		Base:  &ast.Base{Offset: ftOffs, File: p.tfile},
+		Ident: &ast.Ident{Base: &ast.Base{Offset: ioffs, File: p.tfile}, Name: ilit},
+	}
+	if p.cTok != token.LAMBDA && p.cTok != token.LBRACE && len(params) == 1 && !variadic &&
+		params[0].Type == token.ILLEGAL && params[0].Default == nil {
+		// a function given by name, e.g. A(double)
+		t.Mapper = params[0].Ident
+	} else if t.Fn = p.parseFunctionBody("", params, variadic); t.Fn == nil {
+		return nil
+	}
+	return t
 }
 
 func (p *parser) parseCallReference(assign bool, prev *ast.Call) ast.Node {
 	callOffs := p.cOffs
 	kind := p.cTok
 	p.next()
+	// ##command return the command result as map rather than its output
+	result := kind == token.HASH && p.cTok == token.HASH && p.cOffs == callOffs+1
+	if result {
+		p.next()
+	}
 	name := p.cLit
 	if p.expect(token.IDENT) == -1 {
 		return nil
 	}
 	var args []ast.Node
+	var options []*ast.NamedArg
 	var redirect *ast.RedirectTo
 
 	for p.cTok != token.LF && p.cTok != token.EOF {
@@ -997,7 +1408,38 @@ func (p *parser) parseCallReference(assign bool, prev *ast.Call) ast.Node {
 			})
 		case token.PIPE:
 			goto end
+		case token.IDENT:
+			if p.nTok == token.ASSIGN && kind == token.AT {
+				offs, name := p.cOffs, p.cLit
+				p.next()
+				p.next()
+				x, _ := p.parseOperand()
+				args = append(args, &ast.NamedArg{Base: &ast.Base{Offset: offs, File: p.tfile}, Name: name, X: x})
+				continue
+			} else if p.nTok == token.ASSIGN {
+				// env, dir and timeout option of an external command
+				offs, name := p.cOffs, p.cLit
+				if name != "env" && name != "dir" && name != "timeout" {
+					p.errorHandler(p.curPos(), "unknown command option %s, expect env, dir or timeout", name)
+					return nil
+				} else if len(args) > 0 || redirect != nil {
+					p.errorHandler(p.curPos(), "command option %s must be given before the arguments", name)
+					return nil
+				}
+				p.next()
+				p.next()
+				x, _ := p.parseOperand()
+				options = append(options, &ast.NamedArg{Base: &ast.Base{Offset: offs, File: p.tfile}, Name: name, X: x})
+				continue
+			}
+			fallthrough
 		default:
+			if len(args) > 0 && redirect == nil {
+				if _, ok := args[len(args)-1].(*ast.NamedArg); ok {
+					p.errorHandler(p.curPos(), "positional argument is not allowed after named argument")
+					return nil
+				}
+			}
 			x, _ := p.parseOperand()
 			if redirect != nil {
 				redirect.Files = append(redirect.Files, x)
@@ -1008,12 +1450,18 @@ func (p *parser) parseCallReference(assign bool, prev *ast.Call) ast.Node {
 	}
 end:
 	if tok := p.cTok; p.cTok == token.LF || p.cTok == token.PIPE {
+		if result && (tok == token.PIPE || redirect != nil) {
+			p.errorHandler(p.curPos(), "command result ## cannot be piped or redirected")
+			return nil
+		}
 		var node ast.Node
 		node = &ast.Call{
-			Base: &ast.Base{Offset: callOffs, File: p.tfile},
-			Kind: kind,
-			Name: name,
-			Args: args,
+			Base:    &ast.Base{Offset: callOffs, File: p.tfile},
+			Kind:    kind,
+			Name:    name,
+			Args:    args,
+			Options: options,
+			Result:  result,
 		}
 
 		p.next()
@@ -1038,6 +1486,38 @@ end:
 	return nil
 }
 
+// isFunctionLit report whether the parenthesis at the current token open the arguments of a function
+// literal, e.g. (a, b) => a + b or (a) { return a }, rather than a parenthesized expression. The
+// tokens are scanned ahead until the closing parenthesis without moving the parser.
+func (p *parser) isFunctionLit() bool {
+	s := *p.s
+	s.errorHandler = func(token.Position, string, ...interface{}) {}
+	depth, tok := 1, p.nTok
+	for {
+		switch tok {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			if depth--; depth == 0 {
+				_, tok, _ = s.Scan()
+				return tok == token.LAMBDA || tok == token.LBRACE && !p.header
+			}
+		case token.LF, token.EOF:
+			return false
+		}
+		_, tok, _ = s.Scan()
+	}
+}
+
+func (p *parser) parseFunctionLit() ast.Node {
+	offs := p.cOffs
+	p.next()
+	if fn := p.parseDeclareFunction(true); fn != nil {
+		return &ast.FunctionLit{Base: &ast.Base{Offset: offs, File: p.tfile}, Fn: fn}
+	}
+	return nil
+}
+
 func (p *parser) parseDeclareFunction(literal bool) *ast.Function {
 	var name string
 	if !literal {
@@ -1046,56 +1526,93 @@ func (p *parser) parseDeclareFunction(literal bool) *ast.Function {
 			return nil
 		}
 	}
-	if args := p.parseDeclareArgument(); args == nil {
-		return nil
-	} else if p.expect(token.RPAREN) != -1 {
-		blcOff := p.cOffs
-		switch p.cTok {
-		case token.LAMBDA:
-			if x := p.parseBinaryExpr(false, token.LowestPrec+1); x != nil {
-				return &ast.Function{
-					Lambda: token.LAMBDA,
-					Args:   args,
-					X:      x,
-				}
-			}
-		case token.LBRACE:
-			p.next()
-			block := &ast.BlockStatement{Base: &ast.Base{Offset: blcOff, File: p.tfile}}
-			if p.parseBlock(false, block) {
-				return &ast.Function{
-					Name:  name,
-					Args:  args,
-					Insts: block,
-				}
-			}
-		default:
-			p.errorHandler(p.curPos(), "unexpected token %s", p.cTok)
+	if params, variadic, ok := p.parseDeclareArgument(); ok && p.expect(token.RPAREN) != -1 {
+		return p.parseFunctionBody(name, params, variadic)
+	}
+	return nil
+}
+
+// parseFunctionBody parse the lambda expression or the block of a function after its arguments.
+func (p *parser) parseFunctionBody(name string, params []*ast.Param, variadic bool) *ast.Function {
+	fn := &ast.Function{Name: name, Args: make([]*ast.Ident, len(params)), Variadic: variadic}
+	for i, param := range params {
+		if param.Type != token.ILLEGAL {
+			p.errorHandler(param.Position(), "type of argument %s is only allowed in target declaration", param.Name)
+			return nil
+		} else if param.Default != nil && fn.Defaults == nil {
+			fn.Defaults = make([]ast.Node, len(params))
+		}
+		fn.Args[i] = param.Ident
+		if param.Default != nil {
+			fn.Defaults[i] = param.Default
 		}
 	}
+	blcOff := p.cOffs
+	switch p.cTok {
+	case token.LAMBDA:
+		if fn.X = p.parseBinaryExpr(false, token.LowestPrec+1); fn.X != nil {
+			fn.Lambda = token.LAMBDA
+			return fn
+		}
+	case token.LBRACE:
+		p.next()
+		fn.Insts = &ast.BlockStatement{Base: &ast.Base{Offset: blcOff, File: p.tfile}}
+		if p.parseBlock(false, fn.Insts) {
+			return fn
+		}
+	default:
+		p.errorHandler(p.curPos(), "unexpected token %s", p.cTok)
+	}
 	return nil
 }
 
-func (p *parser) parseDeclareArgument() []*ast.Ident {
-	var args []*ast.Ident
+// parseDeclareArgument parse the arguments of a function or a target declaration. An argument may
+// have a type, e.g. (env: string), and a default value, e.g. (a, b = 2). The last argument may be
+// variadic, e.g. (a, ...rest).
+func (p *parser) parseDeclareArgument() (params []*ast.Param, variadic bool, ok bool) {
+	hasDefault := false
 	for p.cTok != token.RPAREN {
-		if p.cTok == token.IDENT {
-			args = append(args, &ast.Ident{Base: &ast.Base{Offset: p.cOffs, File: p.tfile}, Name: p.cLit})
-			if p.next(); p.cTok == token.COMMA {
-				p.next()
-			}
-		} else {
+		if variadic {
+			p.errorHandler(p.curPos(), "variadic argument must be the last argument")
+			return nil, false, false
+		} else if p.cTok == token.ELLIPSIS {
+			variadic = true
+			p.next()
+		}
+		if p.cTok != token.IDENT {
 			p.errorHandler(p.curPos(), "expect identifier but got %s", p.cTok)
-			return nil
+			return nil, false, false
+		}
+		param := &ast.Param{Ident: &ast.Ident{Base: &ast.Base{Offset: p.cOffs, File: p.tfile}, Name: p.cLit}}
+		if p.next(); p.cTok == token.COLON {
+			if p.next(); p.cTok < token.TINTEGER || token.TOBJECT < p.cTok {
+				p.errorHandler(p.curPos(), "expect type but got %s", p.cTok)
+				return nil, false, false
+			}
+			param.Type = p.cTok
+			p.next()
+		}
+		if p.cTok == token.ASSIGN && !variadic {
+			if param.Default = p.parseBinaryExpr(false, token.LowestPrec+1); param.Default == nil {
+				return nil, false, false
+			}
+			hasDefault = true
+		} else if hasDefault && !variadic {
+			p.errorHandler(param.Position(), "argument %s require a default value as it follow an argument which has one", param.Name)
+			return nil, false, false
+		}
+		params = append(params, param)
+		if p.cTok == token.COMMA {
+			p.next()
 		}
 	}
-	return args
+	return params, variadic, true
 }
 
 func parseArrayFile(n ast.Node, tok token.Token) (isGlob bool, x []ast.Node) {
 	if tok == token.STRING {
 		bl := n.(*ast.BasicLit)
-		if mes, err := filepath.Glob(bl.Lit); err != nil || len(mes) == 0 {
+		if mes, err := glob.Glob(bl.Lit); err != nil || len(mes) == 0 {
 			return false, nil
 		} else {
 			for _, sf := range mes {
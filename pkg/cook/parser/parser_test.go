@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/cozees/cook/pkg/cook/ast"
@@ -80,6 +82,63 @@ var simpleCases = []*parserInputCase{
 	/* case 53 */ {in: "if @print exists {}", out: "if @print exists {\n}\n"},
 	/* case 54 */ {in: "if #rmdir exists {}", out: "if #rmdir exists {\n}\n"},
 	/* case 55 */ {in: "if #rmdir exists && on windows {}", out: "if #rmdir exists && on windows {\n}\n"},
+	/* case 56 */ {in: "build: generate lint", out: "build: generate lint\n"},
+	/* case 57 */ {in: "build: generate\n  A = 1", out: "build: generate\nA = 1\n"},
+	/* case 58 */ {in: "build: generate generate", out: ""},
+	/* case 59 */ {in: "build: generate < 'main.go' SRCS > 'bin/app'", out: "build: generate < 'main.go' SRCS > 'bin/app'\n"},
+	/* case 60 */ {in: "build: > 'bin/app'\n  A = 1", out: "build: > 'bin/app'\nA = 1\n"},
+	/* case 61 */ {in: "initialize: > 'bin/app'", out: ""},
+	/* case 62 */ {in: "trace\nA = 1", out: "trace\n\nA = 1\n"},
+	/* case 63 */ {in: "A = 1\ntrace", out: ""},
+	/* case 64 */ {in: "add(a, b = a*2, ...rest) => a + b", out: "add(a, b = a * 2, ...rest) => a + b"},
+	/* case 65 */ {in: "join(...items) {\n return items\n }", out: "join(...items) {\nreturn items\n}"},
+	/* case 66 */ {in: "A = @add 1 b=[1, 2]", out: "A = @add 1 b=[1, 2]\n"},
+	/* case 67 */ {in: "add(a = 1, b) => a + b", out: ""},
+	/* case 68 */ {in: "add(...rest, a) => a", out: ""},
+	/* case 69 */ {in: "@add b=1 2", out: ""},
+	/* case 70 */ {in: "deploy(env: string, replicas: integer = 1):\n@print env", out: "deploy(env: string, replicas: integer = 1):\n@print env\n"},
+	/* case 71 */ {in: "deploy(env, ...rest):\n@print env", out: ""},
+	/* case 72 */ {in: "add(a: integer) => a", out: ""},
+	/* case 73 */ {in: "deploy(env, env):\n@print env", out: ""},
+	/* case 74 */ {in: "f = (a, b = 2) => a * b", out: "f = (a, b = 2) => a * b\n"},
+	/* case 75 */ {in: "f = (x) {\nreturn x\n}", out: "f = (x) {\nreturn x\n}\n"},
+	/* case 76 */ {in: "make(n) => (x) => x + n", out: "make(n) => (x) => x + n"},
+	/* case 77 */ {in: "B = A(double)", out: "B = A(double)\n"},
+	/* case 78 */ {in: "if (a) {\nA = 1\n}", out: "if (a) {\nA = 1\n}\n"},
+	/* case 79 */ {in: "A = (a + 1) * 2", out: "A = (a + 1) * 2\n"},
+	/* case 80 */ {in: "try {\nA = 1\n} catch err {\n@print err['message']\n}", out: "try {\nA = 1\n} catch err {\n@print err['message']\n}\n"},
+	/* case 81 */ {in: "try {\nA = 1\n} catch {\nraise 'failed'\n}", out: "try {\nA = 1\n} catch {\nraise 'failed'\n}\n"},
+	/* case 82 */ {in: "try {\nA = 1\n}", out: ""},
+	/* case 83 */ {in: "if a {\nA = [1][5]\n}", out: ""},
+	/* case 84 */ {in: "R = ##go 'version'\n##make test", out: "R = ##go 'version'\n##make test\n"},
+	/* case 85 */ {in: "##ls | #wc '-l'", out: ""},
+	/* case 86 */ {in: "R = ##ls > 'a.txt'", out: ""},
+	/* case 87 */ {in: "#go dir='src' env={'GOOS': 'linux'} timeout=60 'build'", out: "#go dir='src' env={'GOOS': 'linux'} timeout=60 'build'\n"},
+	/* case 88 */ {in: "#go 'build' dir='src'", out: ""},
+	/* case 89 */ {in: "#go cwd='src' 'build'", out: ""},
+	/* case 90 */ {in: "S = spawn #python3 '-m' 'http.server'\nspawn @build 1\nR = wait S\nwait", out: "S = spawn #python3 '-m' 'http.server'\nspawn @build 1\nR = wait S\nwait\n"},
+	/* case 91 */ {in: "S = spawn A + 1", out: ""},
+	/* case 92 */ {in: "A = [1 2]", out: ""},
+	/* case 93 */ {in: "export GOOS = 'linux'\nexport GOARCH\nexport V = @pbase A", out: "export GOOS = 'linux'\nexport GOARCH\nexport V = @pbase A\n"},
+	/* case 94 */ {in: "unset GOOS GOARCH\nA = 1", out: "unset GOOS GOARCH\nA = 1\n"},
+	/* case 95 */ {in: "export 'GOOS' = 1", out: ""},
+	/* case 96 */ {in: "export GOOS + 1", out: ""},
+	/* case 97 */ {in: "unset\nA = 1", out: ""},
+	/* case 98 */ {in: "trace:\n@print 'trace'", out: "trace:\n@print 'trace'\n"},
+	/* case 99 */ {in: "build: trace\n@print 1\ntrace:\n@print 2", out: "build: trace\n@print 1\n\ntrace:\n@print 2\n"},
+	/* case 100 */ {in: "trace(a) => a\ntrace = @trace 1\n@print trace", out: "trace = @trace 1\n@print trace\ntrace(a) => a"},
+	/* case 101 */ {in: "trace\ntrace += 1", out: "trace\n\ntrace += 1\n"},
+	/* case 102 */ {in: "try:\n@print 'try'", out: "try:\n@print 'try'\n"},
+	/* case 103 */ {in: "raise(e) => e\ncatch = @raise 1\ntry {\nraise catch\n} catch try {\n@print try\n}", out: "catch = @raise 1\ntry {\nraise catch\n} catch try {\n@print try\n}\nraise(e) => e"},
+	/* case 104 */ {in: "build: wait\nj = spawn @wait\nwait j\nwait:\n@print 'wait'", out: "build: wait\nj = spawn @wait\nwait j\n\nwait:\n@print 'wait'\n"},
+	/* case 105 */ {in: "wait(spawn) => spawn\nspawn = 1\nwait += 1\n@print spawn wait", out: "spawn = 1\nwait += 1\n@print spawn wait\nwait(spawn) => spawn"},
+	/* case 106 */ {in: "build: export\n@print 'build'\nexport:\nexport GOOS = 'linux'", out: "build: export\n@print 'build'\n\nexport:\nexport GOOS = 'linux'\n"},
+	/* case 107 */ {in: "unset(a) => a\nexport unset = 1\nexport = [unset]\nunset export unset", out: "export unset = 1\nexport = [unset]\nunset export unset\nunset(a) => a"},
+	/* case 108 */ {in: "j = spawn @print 1\nwait (j)", out: "j = spawn @print 1\nwait (j)\n"},
+	/* case 109 */ {in: "j = spawn @print 1\nx = wait (j)", out: "j = spawn @print 1\nx = wait (j)\n"},
+	/* case 110 */ {in: "build:\nwait (j)\nwait(a):\n@print a", out: "build:\nwait (j)\n\nwait(a):\n@print a\n"},
+	/* case 111 */ {in: "raise ('a' + 'b')", out: "raise ('a' + 'b')\n"},
+	/* case 112 */ {in: "raise(e) {\nraise (e)\n}", out: "raise(e) {\nraise (e)\n}"},
 }
 
 func TestParseSimpleStatement(t *testing.T) {
@@ -185,3 +244,105 @@ func TestParseCode(t *testing.T) {
 	c.Visit(cb)
 	assert.Equal(t, src[1:], cb.String())
 }
+
+const docSrc = `
+// double multiply x by two
+double(x) => x * 2
+
+// build the application
+// for release
+build:
+    A = 1
+
+// not a documentation
+
+generate:
+    /* comment right above a target is its documentation */
+test:
+    @print 'test'
+`
+
+func TestParseDocumentation(t *testing.T) {
+	p := NewParser()
+	c, err := p.ParseSrc(token.NewFile("sample", len(docSrc)), []byte(docSrc))
+	require.NoError(t, err)
+	targets := c.Targets()
+	require.Len(t, targets, 3)
+	assert.Equal(t, "build the application\nfor release", targets[0].Doc)
+	assert.Equal(t, 7, targets[0].Position().Line)
+	assert.Equal(t, "", targets[1].Doc)
+	assert.Equal(t, "comment right above a target is its documentation", targets[2].Doc)
+	fns := c.Functions()
+	require.Len(t, fns, 1)
+	assert.Equal(t, "double multiply x by two", fns[0].Doc)
+	assert.Equal(t, 3, fns[0].Position().Line)
+
+	// comment at the end of a file is not the documentation of a target in the included file
+	dir := t.TempDir()
+	main, second := filepath.Join(dir, "Cookfile"), filepath.Join(dir, "Cookfile.second")
+	require.NoError(t, ioutil.WriteFile(main, []byte("include 'Cookfile.second'\n\nA = 1\n// not a documentation\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(second, []byte("B = 1\nC = 2\nD = 3\nE = 4\nbuild:\n    @print 'build'\n"), 0644))
+	c, err = NewParser().Parse(main)
+	require.NoError(t, err)
+	require.Len(t, c.Targets(), 1)
+	assert.Equal(t, 5, c.Targets()[0].Position().Line)
+	assert.Equal(t, "", c.Targets()[0].Doc)
+}
+
+func TestParseInclude(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Cookfile":        "include 'Cookfile.a'\ninclude 'Cookfile.b'\nA = 1\n",
+		"Cookfile.a":      "include 'Cookfile.shared'\nB = 1\n",
+		"Cookfile.b":      "C = 1\n",
+		"Cookfile.shared": "D = 1\n",
+	}
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	c, err := NewParser().Parse(filepath.Join(dir, "Cookfile"))
+	require.NoError(t, err)
+	includes := c.Includes()
+	assert.ElementsMatch(t, []string{
+		filepath.Join(dir, "Cookfile.a"),
+		filepath.Join(dir, "Cookfile.b"),
+		filepath.Join(dir, "Cookfile.shared"),
+	}, includes)
+}
+
+var formatCases = []*parserInputCase{
+	/* case 1 */ {in: "A=1\nB  =  A+2*3\n", out: "A = 1\nB = A + 2 * 3\n"},
+	/* case 2 */ {in: "A = (1 + 2) * 3 // result 9\n", out: "A = (1 + 2) * 3 // result 9\n"},
+	/* case 3 */ {in: "include 'a.cook'\ntrace\nA = ['*.go']\n", out: "include 'a.cook'\ntrace\n\nA = ['*.go']\n"},
+	/* case 4 */ {in: "\n\nA = 1\n\n\n// b\nB = 2\n", out: "A = 1\n\n// b\nB = 2\n"},
+	/* case 5 */ {
+		in:  "build: test\n\t// first\n\tif A > 1 { // more\n\t@print A\n\t}\n\n\t// end of build\n// test\ntest:\n  @print 'test'\n",
+		out: "build: test\n    // first\n    if A > 1 { // more\n        @print A\n    }\n\n    // end of build\n\n// test\ntest:\n    @print 'test'\n",
+	},
+	/* case 6 */ {
+		in:  "A = {'name': 'cook', 'description': 'a simple task runner', 'keywords': ['build', 'task']}\n",
+		out: "A = {\n    'name': 'cook',\n    'description': 'a simple task runner',\n    'keywords': ['build', 'task'],\n}\n",
+	},
+	/* case 7 */ {in: "A = [\n1, 2]\ndouble(x){\nreturn x*2 // twice\n}\nB = 1", out: "A = [\n    1,\n    2,\n]\n\ndouble(x) {\n    return x * 2 // twice\n}\n\nB = 1\n"},
+	/* case 8 */ {in: "A = ", out: ""},
+	/* case 9 */ {in: "add(a,b=2,...rest)=>a+b\nA = @add 1 b=3\n", out: "add(a, b = 2, ...rest) => a + b\n\nA = @add 1 b=3\n"},
+	/* case 10 */ {in: "deploy(env:string,n:integer=1): build\n@print env n\n", out: "deploy(env: string, n: integer = 1): build\n    @print env n\n"},
+	/* case 11 */ {in: "all:\ntry{\n#make\n}catch e{\nraise e\n}\n", out: "all:\n    try {\n        #make\n    } catch e {\n        raise e\n    }\n"},
+}
+
+func TestFormat(t *testing.T) {
+	for i, tc := range formatCases {
+		t.Logf("TestFormat case #%d", i+1)
+		result, err := Format(token.NewFile("sample", len(tc.in)), []byte(tc.in), 60)
+		if tc.out == "" {
+			assert.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.out, string(result))
+		// formatting a formatted source does not change it
+		again, err := Format(token.NewFile("sample", len(result)), result, 60)
+		require.NoError(t, err)
+		assert.Equal(t, string(result), string(again))
+	}
+}
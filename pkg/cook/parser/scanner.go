@@ -69,11 +69,11 @@ func NewScannerSrc(file *token.File, src []byte, eh ErrorHandler) (*scanner, err
 
 func (s *scanner) next() {
 	if s.rdOffset < len(s.src) {
+		s.offset = s.rdOffset
 		if s.ch == '\n' {
 			s.lineOffset = s.offset
 			s.file.AddLine(s.offset)
 		}
-		s.offset = s.rdOffset
 		r, w := rune(s.src[s.rdOffset]), 1
 		switch {
 		case r == 0:
@@ -166,8 +166,9 @@ revisit:
 						skipLineFeed = false
 						tok = token.BOOLEAN
 					} else {
-						switch tok {
-						case token.IDENT, token.BREAK, token.CONTINUE, token.RETURN:
+						switch {
+						case tok == token.IDENT, tok == token.BREAK, tok == token.CONTINUE, tok == token.RETURN,
+							tok.IsContextual():
 							skipLineFeed = false
 						}
 					}
@@ -255,7 +256,16 @@ revisit:
 			}
 		case '/':
 			if s.ch == '/' || s.ch == '*' {
+				if s.ch == '/' && !s.skipLineFeed && s.prevTok[1] != token.ILLEGAL {
+					// comment at the end of a line terminate the statement, line feed is return
+					// first then the comment is scanned on the next call.
+					s.offset, s.rdOffset, s.ch = offset, offset+1, '/'
+					s.skipLineFeed = true
+					s.mode &^= scanArgument
+					return offset, token.LF, "\n"
+				}
 				tok, lit = token.COMMENT, s.scanComment()
+				s.file.AddComment(offset, lit)
 				skipLineFeed = true
 			} else {
 				tok = s.ternary(s.ch == '=', token.QUO_ASSIGN, token.QUO)
@@ -272,8 +282,14 @@ revisit:
 				return
 			}
 			s.next()
-			tok = token.RANGE
-			lit = ".."
+			if s.ch == '.' {
+				s.next()
+				tok = token.ELLIPSIS
+				lit = "..."
+			} else {
+				tok = token.RANGE
+				lit = ".."
+			}
 			skipLineFeed = true
 		case '[':
 			tok = token.LBRACK
@@ -93,6 +93,16 @@ var stringScanCase = []*testCase{
 			{tok: token.COMMENT, lit: "/* first line comment\nsecond line comment */"},
 		},
 	},
+	{ // case 10
+		src: "var = a // trailing comment",
+		output: []*scanOutput{
+			{tok: token.IDENT, lit: "var"},
+			{tok: token.ASSIGN, lit: "="},
+			{tok: token.IDENT, lit: "a"},
+			{tok: token.LF, lit: "\n"},
+			{tok: token.COMMENT, lit: "// trailing comment"},
+		},
+	},
 }
 
 func TestStringScanner(t *testing.T) {
@@ -113,6 +123,19 @@ func TestStringScanner(t *testing.T) {
 	}
 }
 
+func TestScannerPosition(t *testing.T) {
+	src := "A = 1\n\nfoo:\n    @print A"
+	file := token.NewFile("sample", len(src))
+	s, err := NewScannerSrc(file, []byte(src), nil)
+	require.NoError(t, err)
+	expected := []string{"sample:1:1", "sample:1:3", "sample:1:5", "sample:1:6", "sample:3:1", "sample:3:4", "sample:4:5", "sample:4:6", "sample:4:12"}
+	for i, pos := range expected {
+		t.Logf("TestScannerPosition token #%d", i+1)
+		offset, _, _ := s.Scan()
+		assert.Equal(t, pos, file.Position(offset).String())
+	}
+}
+
 var sources = []*testCase{
 	{
 		src: "var = a",
@@ -10,8 +10,15 @@ type File struct {
 	name string
 	size int
 
-	mutex sync.Mutex
-	lines []int
+	mutex    sync.Mutex
+	lines    []int
+	comments []Comment
+}
+
+// Comment is a comment found in the file, Text include the comment delimiter.
+type Comment struct {
+	Offset int
+	Text   string
 }
 
 func NewFile(name string, size int) *File { return &File{name: name, size: size} }
@@ -33,6 +40,22 @@ func (f *File) AddLine(offset int) {
 	}
 }
 
+// AddComment record a comment at the given offset, comments must be added in order.
+func (f *File) AddComment(offset int, text string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if i := len(f.comments); i == 0 || f.comments[i-1].Offset < offset {
+		f.comments = append(f.comments, Comment{Offset: offset, Text: text})
+	}
+}
+
+// Comments return every comment in the file ordered by its offset.
+func (f *File) Comments() []Comment {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Comment(nil), f.comments...)
+}
+
 func (f *File) ValidateOffset(offset int) int {
 	if offset > f.size {
 		panic(fmt.Sprintf("invalid file offset %d (should be <= %d)", offset, f.size))
@@ -52,9 +75,10 @@ func (f *File) unpack(offset int) (filename string, line, column int) {
 	filename = f.name
 	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
 	if i >= 0 {
-		line, column = i+1, offset-f.lines[i]+1
+		// lines only record the offset where the second line onward begin
+		line, column = i+2, offset-f.lines[i]+1
 	} else {
-		line, column = 1, offset
+		line, column = 1, offset+1
 	}
 	return
 }
@@ -0,0 +1,33 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilePosition(t *testing.T) {
+	// "ab\ncd\n\nef"
+	f := NewFile("sample", 9)
+	f.AddLine(3)
+	f.AddLine(6)
+	f.AddLine(7)
+	tests := []struct {
+		offset       int
+		line, column int
+	}{
+		{offset: 0, line: 1, column: 1}, // case 1
+		{offset: 1, line: 1, column: 2}, // case 2
+		{offset: 3, line: 2, column: 1}, // case 3
+		{offset: 4, line: 2, column: 2}, // case 4
+		{offset: 6, line: 3, column: 1}, // case 5
+		{offset: 8, line: 4, column: 2}, // case 6
+	}
+	for i, tc := range tests {
+		t.Logf("TestFilePosition case #%d", i+1)
+		pos := f.Position(tc.offset)
+		assert.Equal(t, tc.line, pos.Line)
+		assert.Equal(t, tc.column, pos.Column)
+		assert.Equal(t, "sample", pos.Filename)
+	}
+}
@@ -66,6 +66,7 @@ const (
 	DQS            // ??
 	FD             // ~
 	PIPE           // |, it work exclusively in argument scanning
+	ELLIPSIS       // ...
 
 	LBRACK // [
 	LBRACE // {
@@ -91,9 +92,17 @@ const (
 	BREAK
 	CONTINUE
 	INCLUDE
+	TRACE
 	DELETE
 	ON
 	EXISTS
+	TRY
+	CATCH
+	RAISE
+	SPAWN
+	WAIT
+	EXPORT
+	UNSET
 
 	// operating system keyword
 	LINUX
@@ -167,6 +176,7 @@ var tokens = [...]string{
 	DQS:            "??",
 	FD:             "~",
 	PIPE:           "|",
+	ELLIPSIS:       "...",
 	LBRACK:         "[",
 	LBRACE:         "{",
 	RBRACK:         "]",
@@ -188,9 +198,17 @@ var tokens = [...]string{
 	BREAK:          "break",
 	CONTINUE:       "continue",
 	INCLUDE:        "include",
+	TRACE:          "trace",
 	DELETE:         "delete",
 	ON:             "on",
 	EXISTS:         "exists",
+	TRY:            "try",
+	CATCH:          "catch",
+	RAISE:          "raise",
+	SPAWN:          "spawn",
+	WAIT:           "wait",
+	EXPORT:         "export",
+	UNSET:          "unset",
 	LINUX:          "linux",
 	MACOS:          "darwin",
 	WINDOWS:        "windows",
@@ -234,7 +252,7 @@ func IsIdentifier(name string) bool {
 			return false
 		}
 	}
-	return name != "" && !IsKeyword(name)
+	return name != "" && (!IsKeyword(name) || Lookup(name, IDENT).IsContextual())
 }
 
 func (tok Token) IsLiteral() bool { return literal_beg < tok && tok < literal_end }
@@ -245,6 +263,17 @@ func (tok Token) IsOperator() bool {
 
 func (tok Token) IsKeyword() bool { return keyword_beg < tok && tok < keyword_end }
 
+// IsContextual return true if tok is a keyword only at the start of a statement, anywhere else or
+// when it is followed by :, an assignment or the arguments of a declaration it is an identifier,
+// e.g. a target named trace.
+func (tok Token) IsContextual() bool {
+	switch tok {
+	case TRACE, TRY, CATCH, RAISE, SPAWN, WAIT, EXPORT, UNSET:
+		return true
+	}
+	return false
+}
+
 func (tok Token) Kind() reflect.Kind {
 	if literal_beg < tok && tok < IDENT {
 		switch tok {
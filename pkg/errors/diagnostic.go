@@ -0,0 +1,154 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cozees/cook/pkg/cook/token"
+)
+
+// Code is a stable identifier of a kind of diagnostic which tools can rely on.
+type Code string
+
+const (
+	CodeSyntax     Code = "E0100" // the Cookfile cannot be parsed
+	CodeInclude    Code = "E0101" // an included file cannot be found or read
+	CodeDeclare    Code = "E0102" // invalid or duplicate declaration of a target or function
+	CodeUndefined  Code = "E0200" // reference to undefined target, function or variable
+	CodeDependency Code = "E0201" // invalid target dependency such as a cycle
+	CodeType       Code = "E0300" // value cannot be used or converted to the required type
+	CodeIndex      Code = "E0301" // index or range out of bound or missing map key
+	CodeArgument   Code = "E0302" // wrong number of argument given to a function
+	CodeCommand    Code = "E0400" // external command cannot be started or exit with an error
+	CodeFunction   Code = "E0401" // built-in function return an error
+	CodeRuntime    Code = "E0500" // any other error raise while executing Cookfile
+	CodeRaise      Code = "E0501" // error raised by raise statement of the Cookfile
+)
+
+// Severity indicate how serious a diagnostic is.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) { return json.Marshal(s.String()) }
+
+// Frame is an entry of the diagnostic call stack, Kind is either target or function. Position
+// is where the target is declared or where the function is called.
+type Frame struct {
+	Kind     string
+	Name     string
+	Position token.Position
+}
+
+// Diagnostic is an error or a warning at a specific position of the Cookfile.
+type Diagnostic struct {
+	Position token.Position
+	Code     Code
+	Severity Severity
+	Message  string
+	// Stack is the call stack from the outermost target to the innermost function
+	Stack []Frame
+	Err   error
+}
+
+// NewDiagnostic create an error diagnostic from err. If err is or wrap a diagnostic, a copy of it is
+// return so the innermost position is kept, its position and code is only set if it is unknown. An
+// error which wrap the diagnostic become the message of the copy so none of its context is lost, use
+// Wrapf to add context to a diagnostic instead. err itself remain unchanged.
+func NewDiagnostic(pos token.Position, code Code, err error) *Diagnostic {
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		nd := d.copy()
+		if err != error(d) {
+			nd.Message, nd.Err = err.Error(), err
+		}
+		if nd.Position.Line == 0 {
+			nd.Position = pos
+		}
+		if nd.Code == "" {
+			nd.Code = code
+		}
+		return nd
+	}
+	return &Diagnostic{Position: pos, Code: code, Message: err.Error(), Err: err}
+}
+
+// Wrapf return a copy of the diagnostic with its message prefixed by the formatted context. The
+// position, the code and the stack are kept and the diagnostic is available through Unwrap.
+func (d *Diagnostic) Wrapf(format string, args ...interface{}) *Diagnostic {
+	nd := d.copy()
+	nd.Message, nd.Err = fmt.Sprintf(format, args...)+": "+d.Message, d
+	return nd
+}
+
+func (d *Diagnostic) copy() *Diagnostic {
+	nd := *d
+	nd.Stack = append([]Frame(nil), d.Stack...)
+	return &nd
+}
+
+// Errorf create an error diagnostic with a formatted message.
+func Errorf(pos token.Position, code Code, format string, args ...interface{}) *Diagnostic {
+	return NewDiagnostic(pos, code, fmt.Errorf(format, args...))
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Position.Line == 0 && d.Position.Filename == "" {
+		return d.Message
+	}
+	return d.Position.String() + ": " + d.Message
+}
+
+func (d *Diagnostic) Unwrap() error { return d.Err }
+
+// PushFrame add a frame to the bottom of the call stack as the error propagate to the caller.
+func (d *Diagnostic) PushFrame(kind, name string, pos token.Position) {
+	d.Stack = append([]Frame{{Kind: kind, Name: name, Position: pos}}, d.Stack...)
+}
+
+type jsonPosition struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+type jsonFrame struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	jsonPosition
+}
+
+func (d *Diagnostic) MarshalJSON() ([]byte, error) {
+	stack := make([]jsonFrame, len(d.Stack))
+	for i, f := range d.Stack {
+		stack[i] = jsonFrame{Kind: f.Kind, Name: f.Name, jsonPosition: toJSONPosition(f.Position)}
+	}
+	return json.Marshal(&struct {
+		jsonPosition
+		Code     Code        `json:"code"`
+		Severity Severity    `json:"severity"`
+		Message  string      `json:"message"`
+		Stack    []jsonFrame `json:"stack"`
+	}{
+		jsonPosition: toJSONPosition(d.Position),
+		Code:         d.Code,
+		Severity:     d.Severity,
+		Message:      d.Message,
+		Stack:        stack,
+	})
+}
+
+func toJSONPosition(pos token.Position) jsonPosition {
+	return jsonPosition{File: pos.Filename, Line: pos.Line, Column: pos.Column}
+}
@@ -0,0 +1,112 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Report write err to w in the given format. In text format each diagnostic is printed with the
+// offending source line and a caret pointing at the column followed by its call stack. In json
+// format each diagnostic is written as a JSON object on its own line. An error which is not a
+// diagnostic is reported without position.
+func Report(w io.Writer, err error, format string) error {
+	r := &reporter{w: w, sources: make(map[string][][]byte)}
+	for _, d := range diagnosticsOf(err) {
+		if format == FormatJSON {
+			b, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			if _, err = w.Write(append(b, '\n')); err != nil {
+				return err
+			}
+		} else if err := r.text(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diagnosticsOf(err error) []*Diagnostic {
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		return []*Diagnostic{d}
+	}
+	var ce *CookError
+	if errors.As(err, &ce) {
+		var result []*Diagnostic
+		for _, e := range *ce {
+			result = append(result, diagnosticsOf(e)...)
+		}
+		return result
+	}
+	return []*Diagnostic{{Code: CodeRuntime, Message: err.Error(), Err: err}}
+}
+
+type reporter struct {
+	w       io.Writer
+	sources map[string][][]byte
+}
+
+func (r *reporter) text(d *Diagnostic) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+	if d.Position.Line > 0 {
+		gutter := strings.Repeat(" ", len(strconv.Itoa(d.Position.Line)))
+		fmt.Fprintf(b, "%s--> %s\n", gutter, d.Position)
+		if line := r.line(d.Position.Filename, d.Position.Line); line != nil {
+			fmt.Fprintf(b, "%s |\n", gutter)
+			fmt.Fprintf(b, "%d | %s\n", d.Position.Line, line)
+			fmt.Fprintf(b, "%s | %s^\n", gutter, caretIndent(line, d.Position.Column))
+		}
+		for _, f := range d.Stack {
+			if f.Kind == "target" {
+				fmt.Fprintf(b, "%s = in target %s declared at %s\n", gutter, f.Name, f.Position)
+			} else {
+				fmt.Fprintf(b, "%s = in %s %s called at %s\n", gutter, f.Kind, f.Name, f.Position)
+			}
+		}
+	}
+	_, err := io.WriteString(r.w, b.String())
+	return err
+}
+
+// line return the source line without line feed or nil if the file cannot be read.
+func (r *reporter) line(file string, line int) []byte {
+	lines, ok := r.sources[file]
+	if !ok {
+		if b, err := ioutil.ReadFile(file); err == nil {
+			lines = bytes.Split(b, []byte{'\n'})
+		}
+		r.sources[file] = lines
+	}
+	if line > len(lines) {
+		return nil
+	}
+	return bytes.TrimRight(lines[line-1], "\r")
+}
+
+// caretIndent return whitespace which align the caret with the column, tab is kept as is so the
+// caret stay aligned regardless of tab width.
+func caretIndent(line []byte, column int) string {
+	b := &strings.Builder{}
+	for i := 0; i < column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
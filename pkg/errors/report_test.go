@@ -0,0 +1,85 @@
+package errors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cozees/cook/pkg/cook/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Cookfile")
+	require.NoError(t, ioutil.WriteFile(file, []byte("build:\n\tA = B[2]\n"), 0600))
+
+	d := Errorf(token.Position{Filename: file, Line: 2, Column: 6}, CodeIndex, "index %d out of range", 2)
+	d.PushFrame("function", "double", token.Position{Filename: file, Line: 9, Column: 5})
+	d.PushFrame("target", "build", token.Position{Filename: file, Line: 1, Column: 1})
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Report(buf, d, FormatText))
+	assert.Equal(t, "error[E0301]: index 2 out of range\n"+
+		" --> "+file+":2:6\n"+
+		"  |\n"+
+		"2 | \tA = B[2]\n"+
+		"  | \t    ^\n"+
+		"  = in target build declared at "+file+":1:1\n"+
+		"  = in function double called at "+file+":9:5\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, Report(buf, d, FormatJSON))
+	assert.JSONEq(t, `{"file":"`+file+`","line":2,"column":6,"code":"E0301","severity":"error",
+		"message":"index 2 out of range","stack":[
+		{"kind":"target","name":"build","file":"`+file+`","line":1,"column":1},
+		{"kind":"function","name":"double","file":"`+file+`","line":9,"column":5}]}`, buf.String())
+
+	// parse errors are reported one by one and error without position is reported as is
+	ce := &CookError{}
+	ce.StackError(Errorf(token.Position{Filename: file, Line: 1, Column: 7}, CodeSyntax, "unexpected EOF"))
+	ce.StackError(errors.New("target all is not defined"))
+	buf.Reset()
+	require.NoError(t, Report(buf, ce, FormatText))
+	assert.Equal(t, "error[E0100]: unexpected EOF\n"+
+		" --> "+file+":1:7\n"+
+		"  |\n"+
+		"1 | build:\n"+
+		"  |       ^\n"+
+		"error[E0500]: target all is not defined\n", buf.String())
+}
+
+func TestNewDiagnostic(t *testing.T) {
+	inner := Errorf(token.Position{}, CodeArgument, "too many argument")
+	pos := token.Position{Filename: "Cookfile", Line: 3, Column: 2}
+	assert.Equal(t, "too many argument", inner.Error())
+	d := NewDiagnostic(pos, CodeRuntime, inner)
+	assert.NotSame(t, inner, d)
+	assert.Equal(t, pos, d.Position)
+	assert.Equal(t, CodeArgument, d.Code)
+	assert.Equal(t, "Cookfile:3:2: too many argument", d.Error())
+	// the given diagnostic is unchanged
+	assert.Equal(t, token.Position{}, inner.Position)
+
+	// innermost position is kept
+	d = NewDiagnostic(token.Position{Filename: "Cookfile", Line: 9, Column: 1}, CodeRuntime, d)
+	assert.Equal(t, pos, d.Position)
+
+	// context given by Wrapf is kept
+	wd := d.Wrapf("unable to build %s", "app")
+	assert.Equal(t, "Cookfile:3:2: unable to build app: too many argument", wd.Error())
+	assert.True(t, errors.Is(wd, d))
+	assert.Equal(t, CodeArgument, wd.Code)
+	assert.Equal(t, "Cookfile:3:2: too many argument", d.Error())
+
+	// context of an error wrapping the diagnostic is kept
+	wrapped := fmt.Errorf("unable to build: %w (attempt %d)", d, 2)
+	wd = NewDiagnostic(token.Position{Filename: "Cookfile", Line: 9, Column: 1}, CodeRuntime, wrapped)
+	assert.Equal(t, pos, wd.Position)
+	assert.Equal(t, wrapped.Error(), wd.Message)
+	assert.True(t, errors.Is(wd, d))
+	assert.Equal(t, CodeArgument, wd.Code)
+}
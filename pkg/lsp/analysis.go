@@ -0,0 +1,252 @@
+package lsp
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/cozees/cook/pkg/cook/ast"
+	"github.com/cozees/cook/pkg/cook/token"
+)
+
+func isIdentChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+// line return the text of the zero-based line without line feed.
+func (doc *document) line(n int) string { return lineOf(doc.text, n) }
+
+// fileLine return the text of the zero-based line of the file at path which is either the document
+// or a file it include.
+func (doc *document) fileLine(path string, n int) string {
+	if filepath.Clean(path) == filepath.Clean(doc.path) {
+		return doc.line(n)
+	} else if b, err := ioutil.ReadFile(path); err == nil {
+		return lineOf(string(b), n)
+	}
+	return ""
+}
+
+func lineOf(text string, n int) string {
+	lines := strings.Split(text, "\n")
+	if n < 0 || n >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[n], "\r")
+}
+
+// fromLSP return the position with its character, counted in UTF-16 code units by the protocol,
+// converted to the byte offset in the line which the document is analyzed with.
+func (doc *document) fromLSP(pos Position) Position {
+	line, n := doc.line(pos.Line), 0
+	for i, r := range line {
+		if n >= pos.Character {
+			return Position{pos.Line, i}
+		}
+		n += utf16Len(r)
+	}
+	return Position{pos.Line, len(line) + pos.Character - n}
+}
+
+// toLSP return the range placed on line with its byte offsets converted to UTF-16 code units.
+func toLSP(line string, rng Range) Range {
+	return Range{Start: Position{rng.Start.Line, utf16Offset(line, rng.Start.Character)},
+		End: Position{rng.End.Line, utf16Offset(line, rng.End.Character)}}
+}
+
+func utf16Offset(line string, offset int) int {
+	if offset > len(line) {
+		return utf16Offset(line, len(line)) + offset - len(line)
+	}
+	n := 0
+	for _, r := range line[:offset] {
+		n += utf16Len(r)
+	}
+	return n
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// wordAt return the identifier under the position along with the character placed before it,
+// e.g. @ for a target or a function call, # for an external command.
+func (doc *document) wordAt(pos Position) (prefix byte, word string, rng Range) {
+	line := doc.line(pos.Line)
+	if pos.Character > len(line) {
+		return 0, "", rng
+	}
+	start, end := pos.Character, pos.Character
+	for start > 0 && isIdentChar(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isIdentChar(line[end]) {
+		end++
+	}
+	if start == end {
+		return 0, "", rng
+	}
+	if start > 0 {
+		prefix = line[start-1]
+	}
+	rng = Range{Start: Position{pos.Line, start}, End: Position{pos.Line, end}}
+	return prefix, line[start:end], rng
+}
+
+// rangeAt return the range of the word which start at pos or a single character range.
+func (doc *document) rangeAt(pos Position) Range {
+	line, end := doc.line(pos.Line), pos.Character
+	for end < len(line) && isIdentChar(line[end]) {
+		end++
+	}
+	if end == pos.Character {
+		end++
+	}
+	return Range{Start: pos, End: Position{pos.Line, end}}
+}
+
+// toPosition convert one-based line and byte column to zero-based position.
+func toPosition(p token.Position) Position {
+	pos := Position{Line: p.Line - 1, Character: p.Column - 1}
+	if pos.Line < 0 {
+		pos.Line = 0
+	}
+	if pos.Character < 0 {
+		pos.Character = 0
+	}
+	return pos
+}
+
+func findTarget(cook ast.Cook, name string) *ast.Target {
+	for _, t := range cook.Targets() {
+		if t.Name() == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func findFunction(cook ast.Cook, name string) *ast.Function {
+	for _, fn := range cook.Functions() {
+		if fn.Name == name {
+			return fn
+		}
+	}
+	return nil
+}
+
+// enclosingScope return the block, the arguments and the parameters of the target or the function
+// declared in file which contain line. Every line from a declaration to the next declaration or
+// global statement of the same file belong to the declaration. A nil block is the global scope.
+func enclosingScope(cook ast.Cook, file string, line int) (block *ast.BlockStatement, args []*ast.Ident) {
+	start := 0
+	for _, stmt := range cook.Block().Stmts {
+		if n, ok := stmt.(interface{ Position() token.Position }); ok {
+			if pos := n.Position(); pos.Filename == file && pos.Line <= line && pos.Line > start {
+				start = pos.Line
+			}
+		}
+	}
+	for _, t := range cook.Targets() {
+		if pos := t.Position(); pos.Filename == file && pos.Line <= line && pos.Line > start {
+			start, block, args = pos.Line, t.Insts, nil
+			for _, p := range t.Params {
+				args = append(args, p.Ident)
+			}
+		}
+	}
+	for _, fn := range cook.Functions() {
+		if fn.Base == nil {
+			continue
+		}
+		if pos := fn.Position(); pos.Filename == file && pos.Line <= line && pos.Line > start {
+			start, block, args = pos.Line, fn.Insts, fn.Args
+		}
+	}
+	return
+}
+
+// variableDefinition return the position of the last assignment or declaration of the variable
+// placed before line or the first one if the variable is only assigned after the line. The target
+// or the function which contain the line is searched first then the global scope of the same file
+// and lastly the global scope of the other files. Variables of other targets or functions are not
+// visible.
+func variableDefinition(cook ast.Cook, name, file string, line int) *token.Position {
+	var defs []token.Position
+	addDef := func(id *ast.Ident) {
+		if id != nil && id.Name == name {
+			defs = append(defs, id.Position())
+		}
+	}
+	var walk func(bs *ast.BlockStatement)
+	walk = func(bs *ast.BlockStatement) {
+		if bs == nil {
+			return
+		}
+		for _, stmt := range bs.Stmts {
+			switch s := stmt.(type) {
+			case *ast.AssignStatement:
+				if id, ok := s.Ident.(*ast.Ident); ok {
+					addDef(id)
+				}
+			case *ast.ForStatement:
+				addDef(s.I)
+				addDef(s.Value)
+				walk(s.Insts)
+			case *ast.TryStatement:
+				walk(s.Insts)
+				addDef(s.Err)
+				walk(s.Catch)
+			case *ast.IfStatement:
+				for s != nil {
+					walk(s.Insts)
+					if s.Else == nil {
+						break
+					} else if s.Else.IfStmt == nil {
+						walk(s.Else.Insts)
+						break
+					}
+					s = s.Else.IfStmt
+				}
+			}
+		}
+	}
+	// closest return the last definition of the file placed before line or its first definition
+	closest := func() *token.Position {
+		var found, first *token.Position
+		for i := range defs {
+			if defs[i].Filename != file {
+				continue
+			} else if first == nil {
+				first = &defs[i]
+			}
+			if defs[i].Line <= line && (found == nil || defs[i].Line > found.Line) {
+				found = &defs[i]
+			}
+		}
+		if found == nil {
+			return first
+		}
+		return found
+	}
+	if block, args := enclosingScope(cook, file, line); block != nil || len(args) > 0 {
+		for _, arg := range args {
+			addDef(arg)
+		}
+		walk(block)
+		if found := closest(); found != nil {
+			return found
+		}
+		defs = defs[:0]
+	}
+	walk(cook.Block())
+	if found := closest(); found != nil {
+		return found
+	} else if len(defs) > 0 {
+		return &defs[0]
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+// Package lsp implement a Language Server Protocol server for Cookfile which communicate over
+// a pair of reader and writer, usually standard input and output.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cozees/cook/pkg/cook/ast"
+	"github.com/cozees/cook/pkg/cook/parser"
+	"github.com/cozees/cook/pkg/cook/token"
+	cookErrors "github.com/cozees/cook/pkg/errors"
+	"github.com/cozees/cook/pkg/runtime/function"
+)
+
+type document struct {
+	uri  string
+	path string
+	text string
+	// cook is the result of the last successful parse, it is kept while the document contain error
+	// so navigation keep working while typing.
+	cook ast.Cook
+}
+
+type server struct {
+	conn     *conn
+	docs     map[string]*document
+	shutdown bool
+}
+
+// Serve read requests from r and write responses to w until the client send exit notification
+// or r is closed.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{conn: newConn(r, w), docs: make(map[string]*document)}
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		} else if rerr, ok := err.(*rpcError); ok {
+			if err = s.conn.reply(nil, nil, rerr); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			// notification has no response
+			continue
+		} else if err = s.conn.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) handle(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, // full document is sent on every change
+				"definitionProvider": true,
+				"hoverProvider":      true,
+				"completionProvider": map[string]interface{}{"triggerCharacters": []string{"@", "-"}},
+			},
+			"serverInfo": map[string]string{"name": "cook"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params := &DidOpenTextDocumentParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		params := &DidChangeTextDocumentParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, err
+		} else if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		params := &DidCloseTextDocumentParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics",
+			&PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion":
+		params := &TextDocumentPositionParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, err
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, nil
+		}
+		pos := doc.fromLSP(params.Position)
+		switch msg.Method {
+		case "textDocument/definition":
+			return doc.definition(pos), nil
+		case "textDocument/hover":
+			return doc.hover(pos), nil
+		default:
+			return doc.completion(pos), nil
+		}
+	default:
+		if msg.ID == nil {
+			return nil, nil
+		}
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method " + msg.Method + " is not supported"}
+	}
+}
+
+// update parse the document and publish its diagnostics.
+func (s *server) update(uri, text string) error {
+	doc, ok := s.docs[uri]
+	if !ok {
+		doc = &document{uri: uri, path: uriToPath(uri)}
+		s.docs[uri] = doc
+	}
+	doc.text = text
+	cook, err := parse(doc.path, text)
+	if err == nil {
+		doc.cook = cook
+	}
+	return s.conn.notify("textDocument/publishDiagnostics",
+		&PublishDiagnosticsParams{URI: uri, Diagnostics: doc.diagnostics(err)})
+}
+
+// parse the source guarding against panic from an incomplete source being typed.
+func parse(path, text string) (cook ast.Cook, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			cook, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	return parser.NewParser().ParseSrc(token.NewFile(path, len(text)), []byte(text))
+}
+
+func (doc *document) diagnostics(err error) []Diagnostic {
+	result := []Diagnostic{}
+	if err == nil {
+		return result
+	}
+	var errs []error
+	if ce, ok := err.(*cookErrors.CookError); ok {
+		errs = *ce
+	} else {
+		errs = []error{err}
+	}
+	for _, e := range errs {
+		diag := Diagnostic{Severity: 1, Source: "cook", Message: e.Error()}
+		var d *cookErrors.Diagnostic
+		if errors.As(e, &d) {
+			diag.Code, diag.Message = string(d.Code), d.Message
+			// error from included file is reported at the beginning of the document
+			if filepath.Clean(d.Position.Filename) == filepath.Clean(doc.path) {
+				pos := toPosition(d.Position)
+				diag.Range = toLSP(doc.line(pos.Line), doc.rangeAt(pos))
+			}
+		}
+		result = append(result, diag)
+	}
+	return result
+}
+
+func (doc *document) definition(pos Position) interface{} {
+	if doc.cook == nil {
+		return nil
+	}
+	prefix, word, _ := doc.wordAt(pos)
+	if word == "" {
+		return nil
+	}
+	var found *token.Position
+	if prefix != '@' && prefix != '#' {
+		found = variableDefinition(doc.cook, word, doc.path, pos.Line+1)
+	}
+	if found == nil && prefix != '#' {
+		if t := findTarget(doc.cook, word); t != nil {
+			p := t.Position()
+			found = &p
+		} else if fn := findFunction(doc.cook, word); fn != nil {
+			p := fn.Position()
+			found = &p
+		}
+	}
+	if found == nil || found.Filename == "" {
+		return nil
+	}
+	uri := doc.uri
+	if filepath.Clean(found.Filename) != filepath.Clean(doc.path) {
+		uri = pathToURI(found.Filename)
+	}
+	start := toPosition(*found)
+	rng := Range{Start: start, End: Position{start.Line, start.Character + len(word)}}
+	return &Location{URI: uri, Range: toLSP(doc.fileLine(found.Filename, start.Line), rng)}
+}
+
+func (doc *document) hover(pos Position) interface{} {
+	prefix, word, rng := doc.wordAt(pos)
+	if word == "" {
+		return nil
+	}
+	var value string
+	if prefix == '@' {
+		if doc.cook != nil {
+			if t := findTarget(doc.cook, word); t != nil {
+				value = "```cook\n" + t.Signature() + ":\n```\n" + t.Doc
+			} else if fn := findFunction(doc.cook, word); fn != nil {
+				value = "```cook\n" + fn.Signature() + "\n```\n" + fn.Doc
+			}
+		}
+		if value == "" {
+			if fn := function.GetFunction(word); fn != nil {
+				value = fn.Flags().Help(true, "")
+			}
+		}
+	} else if doc.cook != nil && prefix != '#' {
+		if t := findTarget(doc.cook, word); t != nil && variableDefinition(doc.cook, word, doc.path, pos.Line+1) == nil {
+			value = "```cook\n" + t.Signature() + ":\n```\n" + t.Doc
+		}
+	}
+	if value == "" {
+		return nil
+	}
+	rng = toLSP(doc.line(pos.Line), rng)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: strings.TrimSpace(value)}, Range: &rng}
+}
+
+func (doc *document) completion(pos Position) interface{} {
+	items := []CompletionItem{}
+	line := doc.line(pos.Line)
+	if pos.Character < len(line) {
+		line = line[:pos.Character]
+	}
+	start := len(line)
+	for start > 0 && (isIdentChar(line[start-1]) || line[start-1] == '-') {
+		start--
+	}
+	word := line[start:]
+	switch {
+	case start > 0 && line[start-1] == '@':
+		if doc.cook != nil {
+			for _, t := range doc.cook.Targets() {
+				items = append(items, CompletionItem{Label: t.Name(), Kind: completionModule, Detail: "target", Documentation: markdown(t.Doc)})
+			}
+			for _, fn := range doc.cook.Functions() {
+				items = append(items, CompletionItem{Label: fn.Name, Kind: completionFunction, Detail: fn.Signature(), Documentation: markdown(fn.Doc)})
+			}
+		}
+		for _, name := range function.Names() {
+			fn := function.GetFunction(name)
+			items = append(items, CompletionItem{Label: name, Kind: completionFunction, Detail: fn.Flags().ShortDesc})
+		}
+	case strings.HasPrefix(word, "-"):
+		// flags of the last built-in function called on the line
+		i := strings.LastIndexByte(line[:start], '@')
+		if i == -1 {
+			break
+		}
+		end := i + 1
+		for end < len(line) && isIdentChar(line[end]) {
+			end++
+		}
+		fn := function.GetFunction(line[i+1 : end])
+		if fn == nil {
+			break
+		}
+		for _, fl := range fn.Flags().Flags {
+			if fl.Short != "" {
+				items = append(items, CompletionItem{Label: "-" + fl.Short, Kind: completionProperty, Detail: fl.Description})
+			}
+			items = append(items, CompletionItem{Label: "--" + fl.Long, Kind: completionProperty, Detail: fl.Description})
+		}
+	}
+	result := items[:0]
+	for _, item := range items {
+		if strings.HasPrefix(item.Label, word) {
+			result = append(result, item)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Label < result[j].Label })
+	return result
+}
+
+func markdown(doc string) *MarkupContent {
+	if doc == "" {
+		return nil
+	}
+	return &MarkupContent{Kind: "markdown", Value: doc}
+}
+
+func uriToPath(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return filepath.FromSlash(u.Path)
+	}
+	return uri
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
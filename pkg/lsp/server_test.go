@@ -0,0 +1,227 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type client struct {
+	t    *testing.T
+	conn *conn
+	id   int
+}
+
+// call send a request and return its result skipping any notification sent by the server.
+func (c *client) call(method string, params interface{}) json.RawMessage {
+	c.id++
+	require.NoError(c.t, c.conn.write(map[string]interface{}{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params}))
+	for {
+		msg := c.read()
+		if msg["id"] != nil {
+			require.Nil(c.t, msg["error"], "%s", msg["error"])
+			return msg["result"]
+		}
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	require.NoError(c.t, c.conn.notify(method, params))
+}
+
+func (c *client) read() map[string]json.RawMessage {
+	header, err := c.conn.r.ReadMIMEHeader()
+	require.NoError(c.t, err)
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	require.NoError(c.t, err)
+	body := make([]byte, length)
+	_, err = io.ReadFull(c.conn.r.R, body)
+	require.NoError(c.t, err)
+	msg := make(map[string]json.RawMessage)
+	require.NoError(c.t, json.Unmarshal(body, &msg))
+	return msg
+}
+
+const sampleSrc = `VERSION = '1.0'
+
+// double return twice the value
+double(x) {
+    return x * 2
+}
+
+// generate source code
+generate:
+    @print VERSION
+
+build: generate
+    A = @double 2
+    @generate
+    @rm '-r' 'bin'
+`
+
+const scopeSrc = `NAME = 'global'
+
+lint:
+    generate = 1
+    NAME = 'lint'
+
+generate:
+    @print NAME
+
+build: generate
+    NAME = 'build'
+    @print NAME
+`
+
+const unicodeSrc = `generate:
+    @print 'génération'
+
+build:
+    @print '😀' generate
+`
+
+func TestServer(t *testing.T) {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	done := make(chan error)
+	go func() { done <- Serve(sr, sw) }()
+	c := &client{t: t, conn: newConn(cr, cw)}
+
+	var initResult struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	require.NoError(t, json.Unmarshal(c.call("initialize", map[string]interface{}{}), &initResult))
+	assert.Equal(t, true, initResult.Capabilities["hoverProvider"])
+	c.notify("initialized", map[string]interface{}{})
+
+	// diagnostics
+	uri := "file:///tmp/Cookfile"
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "text": "build:\n    A = \n"},
+	})
+	var diags PublishDiagnosticsParams
+	require.NoError(t, json.Unmarshal(c.read()["params"], &diags))
+	assert.Equal(t, uri, diags.URI)
+	require.NotEmpty(t, diags.Diagnostics)
+	assert.Equal(t, "E0100", diags.Diagnostics[0].Code)
+	assert.Equal(t, 1, diags.Diagnostics[0].Range.Start.Line)
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri},
+		"contentChanges": []interface{}{map[string]interface{}{"text": sampleSrc}},
+	})
+	require.NoError(t, json.Unmarshal(c.read()["params"], &diags))
+	assert.Empty(t, diags.Diagnostics)
+
+	at := func(line, char int) map[string]interface{} {
+		return map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+			"position":     map[string]interface{}{"line": line, "character": char},
+		}
+	}
+
+	// go to definition of a variable, a function, a target call and a dependency
+	definitions := []struct {
+		line, char int
+		expected   *Location
+	}{
+		{9, 12, &Location{URI: uri, Range: Range{Position{0, 0}, Position{0, 7}}}},
+		{12, 10, &Location{URI: uri, Range: Range{Position{3, 0}, Position{3, 6}}}},
+		{13, 7, &Location{URI: uri, Range: Range{Position{8, 0}, Position{8, 8}}}},
+		{11, 9, &Location{URI: uri, Range: Range{Position{8, 0}, Position{8, 8}}}},
+		{4, 12, &Location{URI: uri, Range: Range{Position{3, 7}, Position{3, 8}}}},
+		{14, 6, nil},
+	}
+	for i, tc := range definitions {
+		t.Logf("TestServer definition case #%d", i+1)
+		var loc *Location
+		require.NoError(t, json.Unmarshal(c.call("textDocument/definition", at(tc.line, tc.char)), &loc))
+		assert.Equal(t, tc.expected, loc)
+	}
+
+	// hover on built-in function and target
+	var hover *Hover
+	require.NoError(t, json.Unmarshal(c.call("textDocument/hover", at(14, 6)), &hover))
+	require.NotNil(t, hover)
+	assert.Equal(t, "markdown", hover.Contents.Kind)
+	assert.Contains(t, hover.Contents.Value, "rm")
+	require.NoError(t, json.Unmarshal(c.call("textDocument/hover", at(13, 7)), &hover))
+	assert.Contains(t, hover.Contents.Value, "generate source code")
+
+	// variable of another target neither hide the global variable nor the target
+	scopeURI := "file:///tmp/scope/Cookfile"
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": scopeURI, "text": scopeSrc},
+	})
+	require.NoError(t, json.Unmarshal(c.read()["params"], &diags))
+	assert.Empty(t, diags.Diagnostics)
+	scopeAt := func(line, char int) map[string]interface{} {
+		return map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": scopeURI},
+			"position":     map[string]interface{}{"line": line, "character": char},
+		}
+	}
+	scopes := []struct {
+		line, char int
+		expected   Range
+	}{
+		{7, 12, Range{Position{0, 0}, Position{0, 4}}},
+		{11, 12, Range{Position{10, 4}, Position{10, 8}}},
+		{9, 8, Range{Position{6, 0}, Position{6, 8}}},
+	}
+	for i, tc := range scopes {
+		t.Logf("TestServer scope case #%d", i+1)
+		var loc *Location
+		require.NoError(t, json.Unmarshal(c.call("textDocument/definition", scopeAt(tc.line, tc.char)), &loc))
+		require.NotNil(t, loc)
+		assert.Equal(t, &Location{URI: scopeURI, Range: tc.expected}, loc)
+	}
+	hover = nil
+	require.NoError(t, json.Unmarshal(c.call("textDocument/hover", scopeAt(9, 8)), &hover))
+	require.NotNil(t, hover)
+	assert.Contains(t, hover.Contents.Value, "generate:")
+
+	// character of a position is counted in UTF-16 code units
+	unicodeURI := "file:///tmp/unicode/Cookfile"
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": unicodeURI, "text": unicodeSrc},
+	})
+	require.NoError(t, json.Unmarshal(c.read()["params"], &diags))
+	assert.Empty(t, diags.Diagnostics)
+	unicodeAt := map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": unicodeURI},
+		"position":     map[string]interface{}{"line": 4, "character": 18},
+	}
+	var loc *Location
+	require.NoError(t, json.Unmarshal(c.call("textDocument/definition", unicodeAt), &loc))
+	assert.Equal(t, &Location{URI: unicodeURI, Range: Range{Position{0, 0}, Position{0, 8}}}, loc)
+	hover = nil
+	require.NoError(t, json.Unmarshal(c.call("textDocument/hover", unicodeAt), &hover))
+	require.NotNil(t, hover)
+	assert.Equal(t, &Range{Position{4, 16}, Position{4, 24}}, hover.Range)
+
+	// completion of function and its flags
+	var items []CompletionItem
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri},
+		"contentChanges": []interface{}{map[string]interface{}{"text": sampleSrc + "    @dou\n    @rm '--\n"}},
+	})
+	c.read()
+	require.NoError(t, json.Unmarshal(c.call("textDocument/completion", at(15, 8)), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, "double", items[0].Label)
+	require.NoError(t, json.Unmarshal(c.call("textDocument/completion", at(16, 11)), &items))
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.Label
+	}
+	assert.Contains(t, labels, "--recursive")
+
+	c.call("shutdown", nil)
+	c.notify("exit", nil)
+	require.NoError(t, <-done)
+}
@@ -16,6 +16,27 @@ var typeMatch = []reflect.Kind{
 	reflect.Interface,
 }
 
+// CompleteVariableType return the candidates to complete the type of variable given via argument
+// such as --VAR:i or --VAR:s:i. Nil is return if word does not use the variable type syntax.
+func CompleteVariableType(word string) []string {
+	if !strings.HasPrefix(word, "--") {
+		return nil
+	}
+	parts := strings.Split(word[2:], ":")
+	last := parts[len(parts)-1]
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || len(last) > 1 {
+		return nil
+	}
+	prefix := word[:len(word)-len(last)]
+	var candidates []string
+	for _, c := range typeCharacters {
+		if candidate := prefix + string(c); strings.HasPrefix(candidate, word) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
 func checkFormat(a string, ignore bool) (k reflect.Kind, err error) {
 	if len(a) == 0 {
 		if !ignore {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
@@ -19,6 +20,19 @@ const (
 	defaultCookfile = "Cookfile"
 )
 
+// reservedFlags is the name of the flags of cook itself, a variable cannot be given with --NAME
+// using one of these names.
+var reservedFlags = map[string]bool{
+	"force":        true,
+	"dry-run":      true,
+	"trace":        true,
+	"list":         true,
+	"jobs":         true,
+	"watch":        true,
+	"error-format": true,
+	"vars-file":    true,
+}
+
 type Redirect uint8
 
 type FunctionMeta struct {
@@ -28,12 +42,72 @@ type FunctionMeta struct {
 	Args     []*FunctionArg
 }
 
+// FormatOptions is the options of fmt command
+type FormatOptions struct {
+	// Write the formatted source back to the file instead of standard output
+	Write bool
+	// Diff print the difference between the source and the formatted source
+	Diff bool
+	// Check report the files which are not formatted without changing them
+	Check bool
+	Files []string
+}
+
 type MainOptions struct {
 	Cookfile string
 	Targets  []string
 	Args     map[string]interface{}
 	FuncMeta *FunctionMeta
 	IsHelp   bool
+	IsList   bool
+	Force    bool
+	DryRun   bool
+	Trace    bool
+	Jobs     int
+	Watch    []string
+	// ErrorFormat is json or text, an empty string is the same as text
+	ErrorFormat string
+
+	// Completion is the shell name which completion script is requested
+	Completion string
+	// CompleteWords is the words being completed by the completion script
+	CompleteWords []string
+	// IsLSP is true when cook should serve language server protocol over standard input and output
+	IsLSP bool
+	// Format is not nil when the Cookfiles should be formatted instead of executed
+	Format *FormatOptions
+	// Vet is the Cookfiles to check for mistakes instead of executing them
+	Vet []string
+	// IsRepl is true when cook should read and evaluate statements interactively, Cookfile is empty
+	// if it is not given and there is no Cookfile in the current directory
+	IsRepl bool
+	// TargetArgs is the arguments given by name to a target, e.g. deploy env=prod, keyed by the
+	// target name then the argument name
+	TargetArgs map[string]map[string]string
+	// VarsFiles is the JSON, YAML or TOML files whose top-level keys are declared as variables, a
+	// variable given by --NAME take precedence over the files
+	VarsFiles []string
+}
+
+// parseTargetArg add argument name=value to the last target given before the argument.
+func parseTargetArg(mo *MainOptions, name, val string) error {
+	if len(mo.Targets) == 0 {
+		return fmt.Errorf("argument %s must follow a target", name)
+	}
+	for i := 0; i < len(name); i++ {
+		if !('a' <= lower(name[i]) && lower(name[i]) <= 'z' || name[i] == '_' || i > 0 && '0' <= name[i] && name[i] <= '9') {
+			return fmt.Errorf("invalid argument name %s", name)
+		}
+	}
+	target := mo.Targets[len(mo.Targets)-1]
+	if mo.TargetArgs == nil {
+		mo.TargetArgs = make(map[string]map[string]string)
+	}
+	if mo.TargetArgs[target] == nil {
+		mo.TargetArgs[target] = make(map[string]string)
+	}
+	mo.TargetArgs[target][name] = val
+	return nil
 }
 
 func ParseMainArgument(args []string) (*MainOptions, error) {
@@ -79,8 +153,66 @@ func ParseMainArgument(args []string) (*MainOptions, error) {
 		return mo, nil
 	}
 
+	// handle completion
+	if len(args) >= 1 && args[0] == "completion" {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("completion require a shell name, either bash, zsh or fish")
+		}
+		mo.Completion = args[1]
+		return mo, nil
+	} else if len(args) >= 1 && args[0] == "__complete" {
+		mo.CompleteWords = append([]string{}, args[1:]...)
+		return mo, nil
+	} else if len(args) == 1 && args[0] == "lsp" {
+		mo.IsLSP = true
+		return mo, nil
+	} else if len(args) >= 1 && args[0] == "fmt" {
+		mo.Format = &FormatOptions{}
+		for _, arg := range args[1:] {
+			switch arg {
+			case "-w":
+				mo.Format.Write = true
+			case "-d":
+				mo.Format.Diff = true
+			case "-check", "--check":
+				mo.Format.Check = true
+			default:
+				if strings.HasPrefix(arg, "-") {
+					return nil, fmt.Errorf("unknown fmt flag %s", arg)
+				}
+				mo.Format.Files = append(mo.Format.Files, arg)
+			}
+		}
+		if len(mo.Format.Files) == 0 {
+			mo.Format.Files = []string{defaultCookfile}
+		}
+		return mo, nil
+	} else if len(args) >= 1 && args[0] == "vet" {
+		var err error
+		for i := 1; i < len(args); i++ {
+			switch arg := args[i]; {
+			case arg == "--error-format" || strings.HasPrefix(arg, "--error-format="):
+				if i, err = parseErrorFormat(mo, args, i); err != nil {
+					return nil, err
+				}
+			case strings.HasPrefix(arg, "-"):
+				return nil, fmt.Errorf("unknown vet flag %s", arg)
+			default:
+				mo.Vet = append(mo.Vet, arg)
+			}
+		}
+		if len(mo.Vet) == 0 {
+			mo.Vet = []string{defaultCookfile}
+		}
+		return mo, nil
+	}
+
 	// handle help
 	if len(args) >= 1 && args[0] == "help" {
+		if len(args) == 2 && args[1] == "targets" {
+			mo.IsList = true
+			return mo, nil
+		}
 		mo.IsHelp = true
 		if len(args) == 2 && strings.HasPrefix(args[1], "@") {
 			mo.FuncMeta = &FunctionMeta{Name: args[1][1:]}
@@ -88,10 +220,59 @@ func ParseMainArgument(args []string) (*MainOptions, error) {
 		return mo, nil
 	}
 
+	// repl accept the same flags as executing a target
+	if len(args) >= 1 && args[0] == "repl" {
+		mo.IsRepl = true
+		args = args[1:]
+	}
+
 	// parse normal argument
+	var err error
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		switch {
+		case arg == "--force":
+			mo.Force = true
+		case arg == "--dry-run":
+			mo.DryRun = true
+		case arg == "--trace":
+			mo.Trace = true
+		case arg == "--list":
+			mo.IsList = true
+		case arg == "--jobs":
+			if i, err = parseJobs(mo, args, i, ""); err != nil {
+				return nil, err
+			}
+		case arg == "--error-format" || strings.HasPrefix(arg, "--error-format="):
+			if i, err = parseErrorFormat(mo, args, i); err != nil {
+				return nil, err
+			}
+		case arg == "--watch" || strings.HasPrefix(arg, "--watch="):
+			pattern := strings.TrimPrefix(strings.TrimPrefix(arg, "--watch"), "=")
+			if arg == "--watch" {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("flag --watch require a glob pattern")
+				}
+				i++
+				pattern = args[i]
+			}
+			if pattern == "" {
+				return nil, fmt.Errorf("flag --watch require a glob pattern")
+			}
+			mo.Watch = append(mo.Watch, pattern)
+		case arg == "--vars-file" || strings.HasPrefix(arg, "--vars-file="):
+			file := strings.TrimPrefix(strings.TrimPrefix(arg, "--vars-file"), "=")
+			if arg == "--vars-file" {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("flag --vars-file require a file path")
+				}
+				i++
+				file = args[i]
+			}
+			if file == "" {
+				return nil, fmt.Errorf("flag --vars-file require a file path")
+			}
+			mo.VarsFiles = append(mo.VarsFiles, file)
 		case strings.HasPrefix(arg, "--"):
 			val := ""
 			ieql := strings.IndexByte(arg, '=')
@@ -107,6 +288,8 @@ func ParseMainArgument(args []string) (*MainOptions, error) {
 			vname, p, s, err := parseFlagFormat(arg[2:ieql])
 			if err != nil {
 				return nil, err
+			} else if reservedFlags[vname] {
+				return nil, fmt.Errorf("--%s is a flag of cook, variable %s can only be given with --vars-file", vname, vname)
 			}
 			// create args if first encouter
 			if mo.Args == nil {
@@ -149,11 +332,21 @@ func ParseMainArgument(args []string) (*MainOptions, error) {
 					mo.Cookfile = args[i]
 				}
 				break
+			} else if strings.HasPrefix(arg, "-j") {
+				if i, err = parseJobs(mo, args, i, arg[2:]); err != nil {
+					return nil, err
+				}
+				break
 			}
 			return nil, ErrVarSyntax
 		default:
 			if len(arg) == 0 || !('a' <= lower(arg[0]) && lower(arg[0]) <= 'z' || arg[0] == '_') {
 				return nil, fmt.Errorf("invalid target name %s", arg)
+			} else if ieql := strings.IndexByte(arg, '='); ieql != -1 {
+				if err = parseTargetArg(mo, arg[:ieql], arg[ieql+1:]); err != nil {
+					return nil, err
+				}
+				break
 			}
 			if mo.Targets == nil {
 				mo.Targets = make([]string, 0, 2)
@@ -161,9 +354,49 @@ func ParseMainArgument(args []string) (*MainOptions, error) {
 			mo.Targets = append(mo.Targets, arg)
 		}
 	}
+	if mo.IsRepl {
+		if len(mo.Targets) > 0 {
+			return nil, fmt.Errorf("repl does not accept target %s", mo.Targets[0])
+		} else if _, err := os.Stat(mo.Cookfile); mo.Cookfile == defaultCookfile && os.IsNotExist(err) {
+			mo.Cookfile = ""
+		}
+	}
 	return mo, nil
 }
 
+// parseErrorFormat set the error format given by the flag at i or by the next argument and return
+// the index of the last argument consumed.
+func parseErrorFormat(mo *MainOptions, args []string, i int) (int, error) {
+	format := strings.TrimPrefix(strings.TrimPrefix(args[i], "--error-format"), "=")
+	if args[i] == "--error-format" && i+1 < len(args) {
+		i++
+		format = args[i]
+	}
+	if format != "text" && format != "json" {
+		return i, fmt.Errorf("invalid error format %q, must be text or json", format)
+	}
+	mo.ErrorFormat = format
+	return i, nil
+}
+
+// parseJobs set number of jobs from val or from the next argument if val is empty and return
+// the index of the last argument consumed.
+func parseJobs(mo *MainOptions, args []string, i int, val string) (int, error) {
+	if val == "" {
+		if i+1 >= len(args) {
+			return i, fmt.Errorf("flag %s require number of jobs", args[i])
+		}
+		i++
+		val = args[i]
+	}
+	jobs, err := strconv.Atoi(val)
+	if err != nil || jobs < 1 {
+		return i, fmt.Errorf("invalid number of jobs %s", val)
+	}
+	mo.Jobs = jobs
+	return i, nil
+}
+
 type Flag struct {
 	Short       string // single character, e.g. -e, -e
 	Long        string // more 2 character, e.g. --name or -name
@@ -277,7 +510,7 @@ func (flag *Flag) Set(fname string, field reflect.Value, val string, nextArg int
 		}
 	default:
 		if nextArg != nil {
-			if t == nextArgKind {
+			if t == nextArgKind || (t == reflect.Interface && nextArgKind != reflect.String) {
 				field.Set(nextArgVal)
 				advance = true
 				break
@@ -297,6 +530,8 @@ func (flag *Flag) Set(fname string, field reflect.Value, val string, nextArg int
 }
 
 type FunctionArg struct {
+	// Name is set when the argument is given by name, only a target accept such argument
+	Name string
 	Val  interface{}
 	Kind reflect.Kind
 }
@@ -310,6 +545,8 @@ type Flags struct {
 	Usage       string
 	ShortDesc   string
 	Description string
+	// KeepArray tell the caller to give an array as a single argument instead of its elements
+	KeepArray bool
 }
 
 func (flags *Flags) Help(md bool, topAnchor string) string {
@@ -390,6 +627,33 @@ func (flags *Flags) checkFlag(arg string) (flag *Flag, fval string, err error) {
 	return
 }
 
+// CheckArgs verify that every string argument which look like a flag is a flag defined by the
+// function. An argument which is not a string, e.g. nil when its value is only known at runtime,
+// is skipped.
+func (flags *Flags) CheckArgs(args []interface{}) error {
+	if flags.Result == nil || flags.ensureStruct() != nil {
+		return nil
+	}
+	val := reflect.New(flags.Result).Elem()
+	for i := 0; i < len(args); i++ {
+		sarg, ok := args[i].(string)
+		if !ok {
+			continue
+		}
+		flag, fval, err := flags.checkFlag(sarg)
+		if err != nil {
+			return err
+		} else if flag == nil || fval != "" {
+			continue
+		}
+		// the next argument is the value of the flag unless it is a boolean flag
+		if field, _, err := findField(flags.Result, val, flag.Long); err == nil && field.Kind() != reflect.Bool {
+			i++
+		}
+	}
+	return nil
+}
+
 // ParseFlagFunction return a pointer to a struct result if no error occurred.
 // Unlike Parse which accept slice of string, `ParseFlagFunction` accept a slice
 // of interface value (any value), in order to avoid parsing back and forth between
@@ -415,6 +415,103 @@ var testMainFlagCases = []*argsCase{
 			Targets: []string{"sample1", "sample2"},
 		},
 	},
+	{
+		input: []string{"--force", "build", "--dry-run", "--name=test"},
+		opts: &MainOptions{
+			Cookfile: defaultCookfile,
+			Args:     map[string]interface{}{"name": "test"},
+			Targets:  []string{"build"},
+			Force:    true,
+			DryRun:   true,
+		},
+	},
+	{
+		input: []string{"-j", "4", "build", "-j8", "test", "--jobs", "2", "--trace"},
+		opts: &MainOptions{
+			Cookfile: defaultCookfile,
+			Targets:  []string{"build", "test"},
+			Trace:    true,
+			Jobs:     2,
+		},
+	},
+	{
+		input: []string{"--watch", "src/**/*.go", "test", "--watch=Cookfile"},
+		opts: &MainOptions{
+			Cookfile: defaultCookfile,
+			Targets:  []string{"test"},
+			Watch:    []string{"src/**/*.go", "Cookfile"},
+		},
+	},
+	{
+		input: []string{"--vars-file", "config.yaml", "--VERSION", "1.2", "--vars-file=local.json", "build"},
+		opts: &MainOptions{
+			Cookfile:  defaultCookfile,
+			Targets:   []string{"build"},
+			Args:      map[string]interface{}{"VERSION": "1.2"},
+			VarsFiles: []string{"config.yaml", "local.json"},
+		},
+	},
+	{
+		input: []string{"--error-format=json", "build", "--error-format", "text"},
+		opts:  &MainOptions{Cookfile: defaultCookfile, Targets: []string{"build"}, ErrorFormat: "text"},
+	},
+	{
+		input: []string{"help", "targets"},
+		opts:  &MainOptions{Cookfile: defaultCookfile, IsList: true},
+	},
+	{
+		input: []string{"-c", "Cooksample", "--list"},
+		opts:  &MainOptions{Cookfile: "Cooksample", IsList: true},
+	},
+	{
+		input: []string{"completion", "zsh"},
+		opts:  &MainOptions{Cookfile: defaultCookfile, Completion: "zsh"},
+	},
+	{
+		input: []string{"lsp"},
+		opts:  &MainOptions{Cookfile: defaultCookfile, IsLSP: true},
+	},
+	{
+		input: []string{"fmt"},
+		opts:  &MainOptions{Cookfile: defaultCookfile, Format: &FormatOptions{Files: []string{defaultCookfile}}},
+	},
+	{
+		input: []string{"fmt", "-w", "-check", "a.cook", "-d", "b.cook"},
+		opts: &MainOptions{Cookfile: defaultCookfile, Format: &FormatOptions{
+			Write: true, Diff: true, Check: true, Files: []string{"a.cook", "b.cook"},
+		}},
+	},
+	{
+		input: []string{"vet"},
+		opts:  &MainOptions{Cookfile: defaultCookfile, Vet: []string{defaultCookfile}},
+	},
+	{
+		input: []string{"vet", "--error-format", "json", "a.cook", "b.cook"},
+		opts:  &MainOptions{Cookfile: defaultCookfile, ErrorFormat: "json", Vet: []string{"a.cook", "b.cook"}},
+	},
+	{
+		input: []string{"repl"},
+		opts:  &MainOptions{IsRepl: true},
+	},
+	{
+		input: []string{"repl", "-c", "Cooksample", "--env", "dev"},
+		opts:  &MainOptions{Cookfile: "Cooksample", IsRepl: true, Args: map[string]interface{}{"env": "dev"}},
+	},
+	{
+		input: []string{"deploy", "env=prod", "replicas=3", "build", "tag=a=b"},
+		opts: &MainOptions{
+			Cookfile: defaultCookfile,
+			Targets:  []string{"deploy", "build"},
+			TargetArgs: map[string]map[string]string{
+				"deploy": {"env": "prod", "replicas": "3"},
+				"build":  {"tag": "a=b"},
+			},
+		},
+	},
+	{
+		input: []string{"__complete", "build", "--X:"},
+		opts:  &MainOptions{Cookfile: defaultCookfile, CompleteWords: []string{"build", "--X:"}},
+	},
 	// test error
 	{
 		input:   []string{"--dict:a", "22", "--dict:i:s", "11:aa"},
@@ -428,6 +525,58 @@ var testMainFlagCases = []*argsCase{
 		input:   []string{"--val:i", "22", "9038"},
 		failure: true,
 	},
+	{
+		input:   []string{"-j", "zero", "build"},
+		failure: true,
+	},
+	{
+		input:   []string{"completion"},
+		failure: true,
+	},
+	{
+		input:   []string{"test", "--watch"},
+		failure: true,
+	},
+	{
+		input:   []string{"build", "--vars-file"},
+		failure: true,
+	},
+	{
+		input:   []string{"--error-format=xml", "build"},
+		failure: true,
+	},
+	{
+		input:   []string{"fmt", "-x"},
+		failure: true,
+	},
+	{
+		input:   []string{"repl", "build"},
+		failure: true,
+	},
+	{
+		input:   []string{"env=prod", "deploy"},
+		failure: true,
+	},
+	{
+		input:   []string{"deploy", "e-nv=prod"},
+		failure: true,
+	},
+	{
+		input:   []string{"vet", "-w"},
+		failure: true,
+	},
+	{
+		input:   []string{"--force=yes", "build"},
+		failure: true,
+	},
+	{
+		input:   []string{"--list:s", "all", "build"},
+		failure: true,
+	},
+	{
+		input:   []string{"--jobs:i", "2", "build"},
+		failure: true,
+	},
 	{
 		input:   []string{"--dict:o", "22"},
 		failure: true,
@@ -456,3 +605,19 @@ func TestMainFlag(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckArgs(t *testing.T) {
+	assert.NoError(t, testFlags.CheckArgs([]interface{}{"-b", "-a", "-text", nil, int64(1), "--flagc=2"}))
+	assert.Error(t, testFlags.CheckArgs([]interface{}{"-b", "-x"}))
+	assert.Error(t, testFlags.CheckArgs([]interface{}{"--flagz", "text"}))
+	assert.Error(t, testFlags.CheckArgs([]interface{}{"-abc"}))
+}
+
+func TestCompleteVariableType(t *testing.T) {
+	assert.Equal(t, []string{"--X:i", "--X:f", "--X:s", "--X:b", "--X:a"}, CompleteVariableType("--X:"))
+	assert.Equal(t, []string{"--X:s"}, CompleteVariableType("--X:s"))
+	assert.Equal(t, []string{"--X:i:i", "--X:i:f", "--X:i:s", "--X:i:b", "--X:i:a"}, CompleteVariableType("--X:i:"))
+	assert.Nil(t, CompleteVariableType("--X"))
+	assert.Nil(t, CompleteVariableType("--X:i:s:"))
+	assert.Nil(t, CompleteVariableType("-X:"))
+}
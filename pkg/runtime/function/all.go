@@ -2,6 +2,9 @@ package function
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strconv"
 
 	"github.com/cozees/cook/pkg/runtime/args"
@@ -19,9 +22,31 @@ type Function interface {
 // store function reference by name
 var funcStore map[string]Function = make(map[string]Function)
 
+// store function which does not modify the file system, network or standard output
+var readOnlyStore map[Function]bool = make(map[Function]bool)
+
 func IsExist(name string) bool         { return funcStore[name] != nil }
 func GetFunction(name string) Function { return funcStore[name] }
 
+// Names return name and alias of every function sorted alphabetically.
+func Names() []string {
+	names := make([]string, 0, len(funcStore))
+	for name := range funcStore {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsReadOnly return true if the function only compute its result from its arguments or by reading
+// the file system. Such function is safe to be executed in dry-run mode.
+func IsReadOnly(f Function) bool { return readOnlyStore[f] }
+
+func registerReadOnlyFunction(f Function) {
+	registerFunction(f)
+	readOnlyStore[f] = true
+}
+
 func registerFunction(f Function) {
 	funcStore[f.Name()] = f
 	if alias := f.Alias(); alias != nil {
@@ -58,6 +83,36 @@ func (bf *BaseFunction) Apply(args []*args.FunctionArg) (interface{}, error) {
 	return i, err
 }
 
+// OutputFunction is a function which write its result to the standard output. ApplyOutput behave
+// the same as Apply except the output is written to w instead.
+type OutputFunction interface {
+	Function
+	ApplyOutput(args []*args.FunctionArg, w io.Writer) (interface{}, error)
+}
+
+type OutputHandler func(f Function, i interface{}, w io.Writer) (interface{}, error)
+
+type outputFunction struct {
+	*BaseFunction
+	handler OutputHandler
+}
+
+func NewOutputFunction(flags *args.Flags, oh OutputHandler, alias ...string) OutputFunction {
+	return &outputFunction{BaseFunction: NewBaseFunction(flags, nil, alias...), handler: oh}
+}
+
+func (of *outputFunction) Apply(args []*args.FunctionArg) (interface{}, error) {
+	return of.ApplyOutput(args, os.Stdout)
+}
+
+func (of *outputFunction) ApplyOutput(args []*args.FunctionArg, w io.Writer) (interface{}, error) {
+	i, err := of.fnFlags.ParseFunctionArgs(args)
+	if i != nil {
+		i, err = of.handler(of, i, w)
+	}
+	return i, err
+}
+
 func toString(i interface{}) (string, error) {
 	switch v := i.(type) {
 	case string:
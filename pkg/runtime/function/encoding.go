@@ -0,0 +1,392 @@
+package function
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cozees/cook/pkg/runtime/args"
+	"gopkg.in/yaml.v3"
+)
+
+func AllEncodingFlags() []*args.Flags {
+	return []*args.Flags{jsonparseFlags, yamlparseFlags, tomlparseFlags, jsonencodeFlags, yamlencodeFlags, tomlencodeFlags}
+}
+
+type parseOptions struct {
+	File bool `flag:"file"`
+	Args []string
+}
+
+type encodeOptions struct {
+	Pretty bool `flag:"pretty"`
+	Args   []interface{}
+}
+
+const (
+	parseDesc = `Returns the %s document as a map or an array, the value of the document is either an integer,
+				 a float, a boolean, a string, an array or a map. A null value is omitted from its map or array
+				 and a date or a time is returned as a string. The document is read from the file given as
+				 argument instead if flag "file" is given.`
+	encodeDesc = `Returns the value as a %s document. The keys of a map are sorted and written as a string.
+				  An array given as argument is encoded as an array and more than one argument is encoded as
+				  an array as well.`
+	parseFileDesc = `Tell the function to read the document from the given file path.`
+	prettyDesc    = `Tell jsonencode function to indent the document with 2 spaces.`
+)
+
+var (
+	parseOptsType  = reflect.TypeOf((*parseOptions)(nil)).Elem()
+	encodeOptsType = reflect.TypeOf((*encodeOptions)(nil)).Elem()
+)
+
+var jsonparseFlags = &args.Flags{
+	Flags:       []*args.Flag{{Short: "f", Long: "file", Description: parseFileDesc}},
+	Result:      parseOptsType,
+	FuncName:    "jsonparse",
+	ShortDesc:   "parse JSON document into a map or an array.",
+	Usage:       "@jsonparse [-f] DOCUMENT",
+	Example:     "@jsonparse -f package.json",
+	Description: fmt.Sprintf(parseDesc, "JSON"),
+}
+
+var yamlparseFlags = &args.Flags{
+	Flags:       []*args.Flag{{Short: "f", Long: "file", Description: parseFileDesc}},
+	Result:      parseOptsType,
+	FuncName:    "yamlparse",
+	ShortDesc:   "parse YAML document into a map or an array.",
+	Usage:       "@yamlparse [-f] DOCUMENT",
+	Example:     "@yamlparse -f config.yaml",
+	Description: fmt.Sprintf(parseDesc, "YAML"),
+}
+
+var tomlparseFlags = &args.Flags{
+	Flags:       []*args.Flag{{Short: "f", Long: "file", Description: parseFileDesc}},
+	Result:      parseOptsType,
+	FuncName:    "tomlparse",
+	ShortDesc:   "parse TOML document into a map.",
+	Usage:       "@tomlparse [-f] DOCUMENT",
+	Example:     "@tomlparse -f Cargo.toml",
+	Description: fmt.Sprintf(parseDesc, "TOML"),
+}
+
+var jsonencodeFlags = &args.Flags{
+	Flags:       []*args.Flag{{Short: "p", Long: "pretty", Description: prettyDesc}},
+	Result:      encodeOptsType,
+	FuncName:    "jsonencode",
+	KeepArray:   true,
+	ShortDesc:   "encode a value as JSON document.",
+	Usage:       "@jsonencode [-p] VALUE",
+	Example:     "@jsonencode -p {'name': 'cook'}",
+	Description: fmt.Sprintf(encodeDesc, "JSON"),
+}
+
+var yamlencodeFlags = &args.Flags{
+	Result:      encodeOptsType,
+	FuncName:    "yamlencode",
+	KeepArray:   true,
+	ShortDesc:   "encode a value as YAML document.",
+	Usage:       "@yamlencode VALUE",
+	Example:     "@yamlencode {'name': 'cook'}",
+	Description: fmt.Sprintf(encodeDesc, "YAML"),
+}
+
+var tomlencodeFlags = &args.Flags{
+	Result:      encodeOptsType,
+	FuncName:    "tomlencode",
+	KeepArray:   true,
+	ShortDesc:   "encode a map as TOML document.",
+	Usage:       "@tomlencode MAP",
+	Example:     "@tomlencode {'package': {'name': 'cook'}}",
+	Description: fmt.Sprintf(encodeDesc, "TOML") + ` The value must be a map.`,
+}
+
+func decodeJSON(b []byte) (v interface{}, err error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(&v); err != nil {
+		return nil, err
+	} else if d.More() {
+		return nil, fmt.Errorf("invalid JSON document, unexpected data after the top-level value")
+	}
+	return v, nil
+}
+
+func decodeYAML(b []byte) (v interface{}, err error) {
+	err = yaml.Unmarshal(b, &v)
+	return
+}
+
+func decodeTOML(b []byte) (interface{}, error) {
+	m := make(map[string]interface{})
+	if err := toml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// toCookValue convert a decoded value into a value which can be used by Cookfile. It return false if
+// the value is null.
+func toCookValue(v interface{}) (interface{}, bool, error) {
+	switch tv := v.(type) {
+	case nil:
+		return nil, false, nil
+	case bool, string, int64, float64:
+		return tv, true, nil
+	case int:
+		return int64(tv), true, nil
+	case uint64:
+		if tv > math.MaxInt64 {
+			return nil, false, fmt.Errorf("integer %d overflow", tv)
+		}
+		return int64(tv), true, nil
+	case float32:
+		return float64(tv), true, nil
+	case json.Number:
+		if i, err := tv.Int64(); err == nil {
+			return i, true, nil
+		}
+		f, err := tv.Float64()
+		return f, err == nil, err
+	case time.Time:
+		// TOML local date and time does not have a time zone
+		switch tv.Location().String() {
+		case "date-local":
+			return tv.Format("2006-01-02"), true, nil
+		case "time-local":
+			return tv.Format("15:04:05.999999999"), true, nil
+		case "datetime-local":
+			return tv.Format("2006-01-02T15:04:05.999999999"), true, nil
+		}
+		return tv.Format(time.RFC3339Nano), true, nil
+	case []interface{}:
+		array := make([]interface{}, 0, len(tv))
+		for _, item := range tv {
+			if cv, ok, err := toCookValue(item); err != nil {
+				return nil, false, err
+			} else if ok {
+				array = append(array, cv)
+			}
+		}
+		return array, true, nil
+	case []map[string]interface{}:
+		array := make([]interface{}, 0, len(tv))
+		for _, item := range tv {
+			cv, _, err := toCookValue(item)
+			if err != nil {
+				return nil, false, err
+			}
+			array = append(array, cv)
+		}
+		return array, true, nil
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(tv))
+		for key, item := range tv {
+			if cv, ok, err := toCookValue(item); err != nil {
+				return nil, false, err
+			} else if ok {
+				m[key] = cv
+			}
+		}
+		return m, true, nil
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(tv))
+		for key, item := range tv {
+			ck, _, err := toCookValue(key)
+			if err != nil {
+				return nil, false, err
+			}
+			switch ck.(type) {
+			case int64, float64, bool, string:
+			default:
+				return nil, false, fmt.Errorf("map key %v is not an integer, a float, a boolean or a string", key)
+			}
+			if cv, ok, err := toCookValue(item); err != nil {
+				return nil, false, err
+			} else if ok {
+				m[ck] = cv
+			}
+		}
+		return m, true, nil
+	default:
+		return nil, false, fmt.Errorf("unsupported value %v (%T)", v, v)
+	}
+}
+
+// fromCookValue convert a Cookfile value into a value which can be encoded, every map key is
+// converted to a string.
+func fromCookValue(v interface{}) (interface{}, error) {
+	switch tv := v.(type) {
+	case []interface{}:
+		array := make([]interface{}, len(tv))
+		for i, item := range tv {
+			var err error
+			if array[i], err = fromCookValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(tv))
+		for key, item := range tv {
+			skey, err := toString(key)
+			if err != nil {
+				return nil, err
+			}
+			if m[skey], err = fromCookValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case int64, float64, bool, string:
+		return tv, nil
+	default:
+		return nil, fmt.Errorf("value %v (%T) cannot be encoded", v, v)
+	}
+}
+
+// Decode parse the document of the given format, either json, yaml or toml, and return its value.
+func Decode(format string, b []byte) (interface{}, error) {
+	var v interface{}
+	var err error
+	switch format {
+	case "json":
+		v, err = decodeJSON(b)
+	case "yaml":
+		v, err = decodeYAML(b)
+	case "toml":
+		v, err = decodeTOML(b)
+	default:
+		return nil, fmt.Errorf("unsupported format %s, expect json, yaml or toml", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cv, ok, err := toCookValue(v)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("%s document is empty", format)
+	}
+	return cv, nil
+}
+
+// DecodeFile parse the file whose format is given by its extension, either .json, .yaml, .yml or
+// .toml, and return its value.
+func DecodeFile(file string) (interface{}, error) {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(file), "."))
+	if format == "yml" {
+		format = "yaml"
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	v, err := Decode(format, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return v, nil
+}
+
+func parseHandler(f Function, i interface{}, format string) (interface{}, error) {
+	opts := i.(*parseOptions)
+	if len(opts.Args) != 1 {
+		return nil, fmt.Errorf("%s require 1 argument", f.Name())
+	}
+	if opts.File {
+		b, err := ioutil.ReadFile(opts.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return Decode(format, b)
+	}
+	return Decode(format, []byte(opts.Args[0]))
+}
+
+func encodeHandler(f Function, i interface{}, fn func(v interface{}, pretty bool) ([]byte, error)) (interface{}, error) {
+	opts := i.(*encodeOptions)
+	var v interface{}
+	switch len(opts.Args) {
+	case 0:
+		return nil, fmt.Errorf("%s require a value", f.Name())
+	case 1:
+		v = opts.Args[0]
+	default:
+		v = opts.Args
+	}
+	ev, err := fromCookValue(v)
+	if err != nil {
+		return nil, err
+	}
+	b, err := fn(ev, opts.Pretty)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func init() {
+	registerReadOnlyFunction(NewBaseFunction(jsonparseFlags, func(f Function, i interface{}) (interface{}, error) {
+		return parseHandler(f, i, "json")
+	}))
+
+	registerReadOnlyFunction(NewBaseFunction(yamlparseFlags, func(f Function, i interface{}) (interface{}, error) {
+		return parseHandler(f, i, "yaml")
+	}))
+
+	registerReadOnlyFunction(NewBaseFunction(tomlparseFlags, func(f Function, i interface{}) (interface{}, error) {
+		return parseHandler(f, i, "toml")
+	}))
+
+	registerReadOnlyFunction(NewBaseFunction(jsonencodeFlags, func(f Function, i interface{}) (interface{}, error) {
+		return encodeHandler(f, i, func(v interface{}, pretty bool) ([]byte, error) {
+			buf := &bytes.Buffer{}
+			e := json.NewEncoder(buf)
+			e.SetEscapeHTML(false)
+			if pretty {
+				e.SetIndent("", "  ")
+			}
+			if err := e.Encode(v); err != nil {
+				return nil, err
+			}
+			return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+		})
+	}))
+
+	registerReadOnlyFunction(NewBaseFunction(yamlencodeFlags, func(f Function, i interface{}) (interface{}, error) {
+		return encodeHandler(f, i, func(v interface{}, pretty bool) ([]byte, error) {
+			buf := &bytes.Buffer{}
+			e := yaml.NewEncoder(buf)
+			e.SetIndent(2)
+			if err := e.Encode(v); err != nil {
+				return nil, err
+			} else if err = e.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		})
+	}))
+
+	registerReadOnlyFunction(NewBaseFunction(tomlencodeFlags, func(f Function, i interface{}) (interface{}, error) {
+		return encodeHandler(f, i, func(v interface{}, pretty bool) ([]byte, error) {
+			if _, ok := v.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("TOML document require a map, given %v", v)
+			}
+			buf := &bytes.Buffer{}
+			e := toml.NewEncoder(buf)
+			e.Indent = ""
+			if err := e.Encode(v); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		})
+	}))
+}
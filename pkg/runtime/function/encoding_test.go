@@ -0,0 +1,131 @@
+package function
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cozees/cook/pkg/runtime/args"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type encodingInOut struct {
+	name   string
+	args   []*args.FunctionArg
+	output interface{}
+	err    bool
+}
+
+var encodingTestCase = []*encodingInOut{
+	{ // case 1
+		name:   "jsonparse",
+		args:   convertToFunctionArgs([]string{`{"a": [1, 2.5, true, null, "x"], "b": {"c": 10000000000}, "d": null}`}),
+		output: map[interface{}]interface{}{"a": []interface{}{int64(1), 2.5, true, "x"}, "b": map[interface{}]interface{}{"c": int64(10000000000)}},
+	},
+	{ // case 2
+		name:   "jsonparse",
+		args:   convertToFunctionArgs([]string{`[{"name": "cook"}, 1]`}),
+		output: []interface{}{map[interface{}]interface{}{"name": "cook"}, int64(1)},
+	},
+	{ // case 3
+		name: "jsonparse",
+		args: convertToFunctionArgs([]string{`{"a": 1} {"b": 2}`}),
+		err:  true,
+	},
+	{ // case 4
+		name:   "yamlparse",
+		args:   convertToFunctionArgs([]string{"name: cook\nversion: 1.2\ndebug: false\ntargets: [linux, darwin]\n1: one\nnothing: ~\n"}),
+		output: map[interface{}]interface{}{"name": "cook", "version": 1.2, "debug": false, "targets": []interface{}{"linux", "darwin"}, int64(1): "one"},
+	},
+	{ // case 5
+		name:   "yamlparse",
+		args:   convertToFunctionArgs([]string{"when: 2021-10-01T10:00:00Z"}),
+		output: map[interface{}]interface{}{"when": "2021-10-01T10:00:00Z"},
+	},
+	{ // case 6
+		name: "yamlparse",
+		args: convertToFunctionArgs([]string{""}),
+		err:  true,
+	},
+	{ // case 7
+		name: "tomlparse",
+		args: convertToFunctionArgs([]string{"title = \"demo\"\ndate = 2021-10-01\nat = 07:32:00\n[server]\nport = 8080\n[[deps]]\nname = \"a\"\n[[deps]]\nname = \"b\"\n"}),
+		output: map[interface{}]interface{}{
+			"title":  "demo",
+			"date":   "2021-10-01",
+			"at":     "07:32:00",
+			"server": map[interface{}]interface{}{"port": int64(8080)},
+			"deps":   []interface{}{map[interface{}]interface{}{"name": "a"}, map[interface{}]interface{}{"name": "b"}},
+		},
+	},
+	{ // case 8
+		name: "tomlparse",
+		args: convertToFunctionArgs([]string{"title = "}),
+		err:  true,
+	},
+	{ // case 9
+		name:   "jsonencode",
+		args:   []*args.FunctionArg{{Val: map[interface{}]interface{}{"b": []interface{}{int64(1), 2.5}, "a": "x&y", "n": map[interface{}]interface{}{int64(1): true}}, Kind: reflect.Map}},
+		output: `{"a":"x&y","b":[1,2.5],"n":{"1":true}}`,
+	},
+	{ // case 10
+		name:   "jsonencode",
+		args:   []*args.FunctionArg{{Val: "-p", Kind: reflect.String}, {Val: int64(1), Kind: reflect.Int64}, {Val: "a", Kind: reflect.String}},
+		output: "[\n  1,\n  \"a\"\n]",
+	},
+	{ // case 11
+		name:   "yamlencode",
+		args:   []*args.FunctionArg{{Val: map[interface{}]interface{}{"name": "cook", "targets": []interface{}{"linux", "darwin"}}, Kind: reflect.Map}},
+		output: "name: cook\ntargets:\n  - linux\n  - darwin\n",
+	},
+	{ // case 12
+		name:   "tomlencode",
+		args:   []*args.FunctionArg{{Val: map[interface{}]interface{}{"title": "demo", "server": map[interface{}]interface{}{"port": int64(8080)}}, Kind: reflect.Map}},
+		output: "title = \"demo\"\n\n[server]\nport = 8080\n",
+	},
+	{ // case 13
+		name: "tomlencode",
+		args: []*args.FunctionArg{{Val: "text", Kind: reflect.String}},
+		err:  true,
+	},
+}
+
+func TestEncoding(t *testing.T) {
+	for i, tc := range encodingTestCase {
+		t.Logf("TestEncoding case #%d", i+1)
+		result, err := GetFunction(tc.name).Apply(tc.args)
+		if tc.err {
+			assert.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.output, result)
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"vars.json": `{"name": "cook"}`,
+		"vars.yml":  "name: cook",
+		"vars.toml": `name = "cook"`,
+		"vars.ini":  "name=cook",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+		v, err := DecodeFile(file)
+		if name == "vars.ini" {
+			assert.Error(t, err)
+		} else {
+			require.NoError(t, err)
+			assert.Equal(t, map[interface{}]interface{}{"name": "cook"}, v)
+		}
+	}
+	// read the document from a file
+	v, err := GetFunction("jsonparse").Apply(convertToFunctionArgs([]string{"-f", filepath.Join(dir, "vars.json")}))
+	require.NoError(t, err)
+	assert.Equal(t, map[interface{}]interface{}{"name": "cook"}, v)
+}
@@ -0,0 +1,155 @@
+package function
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/cozees/cook/pkg/runtime/args"
+)
+
+func AllEnvFlags() []*args.Flags {
+	return []*args.Flags{dotenvFlags}
+}
+
+type dotenvOptions struct {
+	Override bool `flag:"override"`
+	Args     []string
+}
+
+const (
+	dotenvDesc = `Load one or more .env files into the environment variables. Each line of the file has the form
+				  KEY=VALUE, an optional "export" keyword may precede the KEY. Blank lines and lines starting
+				  with # are ignored. A value enclosed in single quote is taken as it is while a value enclosed in
+				  double quote support escape sequence \n, \r, \t, \" and \\. $NAME or ${NAME} in a value which
+				  is not enclosed in single quote is replaced by the value of the variable defined earlier or by
+				  the environment variable. An existing environment variable is not overridden unless the flag
+				  "override" is given. Once loaded, the variable is visible to the Cookfile and to every command
+				  executed afterward. The function return a map of every variable in the files.`
+	dotenvOverrideDesc = `Tell dotenv function to override the environment variable which is already defined.`
+)
+
+var dotenvFlags = &args.Flags{
+	Flags: []*args.Flag{
+		{Short: "o", Long: "override", Description: dotenvOverrideDesc},
+	},
+	Result:      reflect.TypeOf((*dotenvOptions)(nil)).Elem(),
+	FuncName:    "dotenv",
+	ShortDesc:   "load .env files into the environment variables.",
+	Usage:       "@dotenv [-o] FILE [FILE ...]",
+	Example:     "@dotenv '.env' '.env.local'",
+	Description: dotenvDesc,
+}
+
+// parseDotenv read KEY=VALUE pairs from file, the variables loaded from a previous file or line are
+// stored in vars and are used to expand the value of the next line.
+func parseDotenv(file string, vars map[string]string, keys *[]string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lookup := func(name string) string {
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return os.Getenv(name)
+	}
+	scanner := bufio.NewScanner(f)
+	for line := 0; scanner.Scan(); {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, "export "))
+		i := strings.IndexByte(text, '=')
+		if i <= 0 {
+			return fmt.Errorf("%s:%d: expect KEY=VALUE but got %s", file, line, text)
+		}
+		key, value := strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+		if strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("%s:%d: invalid variable name %s", file, line, key)
+		}
+		switch {
+		case value == "":
+		case value[0] == '\'':
+			end := strings.IndexByte(value[1:], '\'')
+			if end < 0 {
+				return fmt.Errorf("%s:%d: missing closing quote '", file, line)
+			}
+			value = value[1 : end+1]
+		case value[0] == '"':
+			buf, escape, closed := &strings.Builder{}, false, false
+			for _, c := range value[1:] {
+				if escape {
+					switch c {
+					case 'n':
+						buf.WriteByte('\n')
+					case 'r':
+						buf.WriteByte('\r')
+					case 't':
+						buf.WriteByte('\t')
+					case '"', '\\', '$':
+						buf.WriteRune(c)
+					default:
+						buf.WriteByte('\\')
+						buf.WriteRune(c)
+					}
+					escape = false
+				} else if c == '\\' {
+					escape = true
+				} else if c == '"' {
+					closed = true
+					break
+				} else {
+					buf.WriteRune(c)
+				}
+			}
+			if !closed {
+				return fmt.Errorf("%s:%d: missing closing quote \"", file, line)
+			}
+			value = os.Expand(buf.String(), lookup)
+		default:
+			// inline comment must be separated from the value by a whitespace
+			if ci := strings.Index(value, " #"); ci >= 0 {
+				value = strings.TrimSpace(value[:ci])
+			}
+			value = os.Expand(value, lookup)
+		}
+		if _, ok := vars[key]; !ok {
+			*keys = append(*keys, key)
+		}
+		vars[key] = value
+	}
+	return scanner.Err()
+}
+
+func init() {
+	registerFunction(NewBaseFunction(dotenvFlags, func(f Function, i interface{}) (interface{}, error) {
+		opts := i.(*dotenvOptions)
+		if len(opts.Args) == 0 {
+			return nil, fmt.Errorf("%s require at least one file", f.Name())
+		}
+		vars, keys := make(map[string]string), make([]string, 0)
+		for _, file := range opts.Args {
+			if err := parseDotenv(file, vars, &keys); err != nil {
+				return nil, err
+			}
+		}
+		result := make(map[interface{}]interface{})
+		for _, key := range keys {
+			if _, ok := os.LookupEnv(key); ok && !opts.Override {
+				result[key] = os.Getenv(key)
+				continue
+			}
+			if err := os.Setenv(key, vars[key]); err != nil {
+				return nil, err
+			}
+			result[key] = vars[key]
+		}
+		return result, nil
+	}))
+}
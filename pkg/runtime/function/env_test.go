@@ -0,0 +1,69 @@
+package function
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDotenv(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("DOTENV_TEST_KEEP", "original")
+	defer func() {
+		for _, name := range []string{"DOTENV_TEST_KEEP", "DOTENV_TEST_A", "DOTENV_TEST_B", "DOTENV_TEST_C", "DOTENV_TEST_D", "DOTENV_TEST_E"} {
+			os.Unsetenv(name)
+		}
+	}()
+	tests := []struct {
+		src      string
+		override bool
+		output   map[interface{}]interface{}
+		err      bool
+	}{
+		{ // case 1
+			src: "# comment\n\nDOTENV_TEST_A=1\nexport DOTENV_TEST_B = value # inline\nDOTENV_TEST_C=\"${DOTENV_TEST_A}\\t$DOTENV_TEST_B\\\"\"\nDOTENV_TEST_D='$DOTENV_TEST_A\\t'\nDOTENV_TEST_E=",
+			output: map[interface{}]interface{}{
+				"DOTENV_TEST_A": "1",
+				"DOTENV_TEST_B": "value",
+				"DOTENV_TEST_C": "1\tvalue\"",
+				"DOTENV_TEST_D": "$DOTENV_TEST_A\\t",
+				"DOTENV_TEST_E": "",
+			},
+		},
+		{ // case 2
+			src:    "DOTENV_TEST_KEEP=changed",
+			output: map[interface{}]interface{}{"DOTENV_TEST_KEEP": "original"},
+		},
+		{ // case 3
+			src:      "DOTENV_TEST_KEEP=changed",
+			override: true,
+			output:   map[interface{}]interface{}{"DOTENV_TEST_KEEP": "changed"},
+		},
+		{src: "DOTENV_TEST_A", err: true},            // case 4
+		{src: "DOTENV_TEST_A=\"unclosed", err: true}, // case 5
+		{src: "DOTENV TEST=1", err: true},            // case 6
+	}
+	for i, tc := range tests {
+		t.Logf("TestDotenv case #%d", i+1)
+		file := filepath.Join(dir, ".env")
+		require.NoError(t, ioutil.WriteFile(file, []byte(tc.src), 0644))
+		args := []string{file}
+		if tc.override {
+			args = append([]string{"-o"}, args...)
+		}
+		result, err := GetFunction("dotenv").Apply(convertToFunctionArgs(args))
+		if tc.err {
+			assert.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.output, result)
+		for k, v := range tc.output {
+			assert.Equal(t, v, os.Getenv(k.(string)))
+		}
+	}
+}
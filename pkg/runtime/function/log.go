@@ -3,6 +3,7 @@ package function
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
 
@@ -42,7 +43,7 @@ var printFlags = &args.Flags{
 	Description: printDesc,
 }
 
-var printFn = NewBaseFunction(printFlags, func(bf Function, i interface{}) (v interface{}, err error) {
+var printFn = NewOutputFunction(printFlags, func(bf Function, i interface{}, w io.Writer) (v interface{}, err error) {
 	opts := i.(*printOption)
 	txt := ""
 	if len(opts.Args) > 0 {
@@ -66,12 +67,12 @@ var printFn = NewBaseFunction(printFlags, func(bf Function, i interface{}) (v in
 		if opts.Echo {
 			return txt, nil
 		}
-		fmt.Print(txt)
+		fmt.Fprint(w, txt)
 	} else {
 		if opts.Echo {
 			return txt + "\n", nil
 		}
-		fmt.Println(txt)
+		fmt.Fprintln(w, txt)
 	}
 	return nil, nil
 })
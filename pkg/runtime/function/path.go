@@ -120,39 +120,39 @@ var prelFlags = &args.Flags{
 }
 
 func init() {
-	registerFunction(NewBaseFunction(pabsFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(pabsFlags, func(f Function, i interface{}) (interface{}, error) {
 		return dHandler(f, i, 1, filepath.Abs)
 	}))
 
-	registerFunction(NewBaseFunction(pbaseFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(pbaseFlags, func(f Function, i interface{}) (interface{}, error) {
 		return sHandler(f, i, 1, filepath.Base)
 	}))
 
-	registerFunction(NewBaseFunction(pextFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(pextFlags, func(f Function, i interface{}) (interface{}, error) {
 		return sHandler(f, i, 1, filepath.Ext)
 	}))
 
-	registerFunction(NewBaseFunction(pdirFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(pdirFlags, func(f Function, i interface{}) (interface{}, error) {
 		return sHandler(f, i, 1, filepath.Dir)
 	}))
 
-	registerFunction(NewBaseFunction(pcleanFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(pcleanFlags, func(f Function, i interface{}) (interface{}, error) {
 		return sHandler(f, i, 1, filepath.Clean)
 	}))
 
-	registerFunction(NewBaseFunction(psplitFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(psplitFlags, func(f Function, i interface{}) (interface{}, error) {
 		return validate(f, i.(*pathOptions), 1, func(s ...string) (interface{}, error) {
 			return strings.Split(s[0], fmt.Sprintf("%c", os.PathSeparator)), nil
 		})
 	}))
 
-	registerFunction(NewBaseFunction(pglobFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(pglobFlags, func(f Function, i interface{}) (interface{}, error) {
 		return validate(f, i.(*pathOptions), 1, func(s ...string) (interface{}, error) {
 			return filepath.Glob(s[0])
 		})
 	}))
 
-	registerFunction(NewBaseFunction(prelFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(prelFlags, func(f Function, i interface{}) (interface{}, error) {
 		return validate(f, i.(*pathOptions), 2, func(s ...string) (interface{}, error) {
 			return filepath.Rel(s[0], s[1])
 		})
@@ -0,0 +1,628 @@
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cozees/cook/pkg/runtime/args"
+)
+
+func AllQueryFlags() []*args.Flags {
+	return []*args.Flags{queryFlags}
+}
+
+type queryOptions struct {
+	Default interface{} `flag:"default"`
+	Args    []interface{}
+}
+
+const (
+	queryDesc = `Returns the value found by following the path in the given map or array. The path is . alone
+				 or a list of segments, .name or ["name"] select the key name of a map, [0] select
+				 the element at an index of an array where negative index count from the end, [*] or [] select
+				 every element of an array or every value of a map, [1:3] select the elements from the start index
+				 until before the end index, ..name select the key name of the map and every map nested in it and
+				 [?(@.age > 30)] select every element or value which satisfy the condition where @ is the element.
+				 A condition compare a path starting with @ to another path or to a literal integer, float, quoted
+				 string, true or false using ==, !=, <, <=, > or >=, conditions can be combined with &&, || and !
+				 and a path alone is true if it exist and its value is not false. An array of every value found is
+				 returned if the path contain [*], a slice, .. or a condition, otherwise the single value is returned
+				 and it is an error if there is none unless flag "default" is given. More than one value given after
+				 the path is queried as an array.`
+	queryDefaultDesc = `Tell query function to return the value instead of an error when the path does not match any value.`
+)
+
+var queryFlags = &args.Flags{
+	Flags: []*args.Flag{
+		{Short: "d", Long: "default", Description: queryDefaultDesc},
+	},
+	Result:      reflect.TypeOf((*queryOptions)(nil)).Elem(),
+	FuncName:    "query",
+	KeepArray:   true,
+	ShortDesc:   "return the values found by a path in a map or an array.",
+	Usage:       "@query [-d DEFAULT] PATH VALUE",
+	Example:     "@query '.items[?(@.size > 10)].name' DATA",
+	Description: queryDesc,
+}
+
+type (
+	queryStep interface {
+		apply(nodes []interface{}) []interface{}
+	}
+
+	fieldStep     string
+	indexStep     int
+	wildcardStep  struct{}
+	recursiveStep struct{}
+	sliceStep     struct {
+		start, end       int
+		hasStart, hasEnd bool
+	}
+	filterStep struct{ cond queryExpr }
+)
+
+// children return the elements of an array or the values of a map ordered by its keys.
+func children(node interface{}) []interface{} {
+	switch n := node.(type) {
+	case []interface{}:
+		return n
+	case map[interface{}]interface{}:
+		keys := make([]interface{}, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		values := make([]interface{}, len(keys))
+		for i, k := range keys {
+			values[i] = n[k]
+		}
+		return values
+	default:
+		if rv := reflect.ValueOf(node); rv.Kind() == reflect.Slice {
+			values := make([]interface{}, rv.Len())
+			for i := range values {
+				values[i] = rv.Index(i).Interface()
+			}
+			return values
+		}
+		return nil
+	}
+}
+
+func (f fieldStep) apply(nodes []interface{}) (result []interface{}) {
+	for _, node := range nodes {
+		if m, ok := node.(map[interface{}]interface{}); ok {
+			if v, ok := m[string(f)]; ok {
+				result = append(result, v)
+			}
+		}
+	}
+	return
+}
+
+func (ix indexStep) apply(nodes []interface{}) (result []interface{}) {
+	for _, node := range nodes {
+		if m, ok := node.(map[interface{}]interface{}); ok {
+			if v, ok := m[int64(ix)]; ok {
+				result = append(result, v)
+			}
+		} else if array := children(node); array != nil {
+			i := int(ix)
+			if i < 0 {
+				i += len(array)
+			}
+			if 0 <= i && i < len(array) {
+				result = append(result, array[i])
+			}
+		}
+	}
+	return
+}
+
+func (wildcardStep) apply(nodes []interface{}) (result []interface{}) {
+	for _, node := range nodes {
+		result = append(result, children(node)...)
+	}
+	return
+}
+
+func (recursiveStep) apply(nodes []interface{}) (result []interface{}) {
+	for _, node := range nodes {
+		result = append(result, node)
+		switch node.(type) {
+		case []interface{}, map[interface{}]interface{}:
+			result = append(result, recursiveStep{}.apply(children(node))...)
+		}
+	}
+	return
+}
+
+func (s sliceStep) apply(nodes []interface{}) (result []interface{}) {
+	for _, node := range nodes {
+		if _, ok := node.(map[interface{}]interface{}); ok {
+			continue
+		}
+		array := children(node)
+		start, end := 0, len(array)
+		if s.hasStart {
+			start = s.start
+		}
+		if s.hasEnd {
+			end = s.end
+		}
+		if start < 0 {
+			start += len(array)
+		}
+		if end < 0 {
+			end += len(array)
+		}
+		if start < 0 {
+			start = 0
+		}
+		if end > len(array) {
+			end = len(array)
+		}
+		if start < end {
+			result = append(result, array[start:end]...)
+		}
+	}
+	return
+}
+
+func (f filterStep) apply(nodes []interface{}) (result []interface{}) {
+	for _, node := range nodes {
+		for _, child := range children(node) {
+			if v, ok := f.cond.eval(child); truthy(v, ok) {
+				result = append(result, child)
+			}
+		}
+	}
+	return
+}
+
+type (
+	// queryExpr is a condition of a filter, eval return false if the value does not exist.
+	queryExpr interface {
+		eval(node interface{}) (interface{}, bool)
+	}
+
+	pathOperand    []queryStep
+	literalOperand struct{ v interface{} }
+	compareExpr    struct {
+		op   string
+		x, y queryExpr
+	}
+	logicalExpr struct {
+		and  bool
+		x, y queryExpr
+	}
+	notExpr struct{ x queryExpr }
+)
+
+func truthy(v interface{}, ok bool) bool { return ok && v != false }
+
+func (po pathOperand) eval(node interface{}) (interface{}, bool) {
+	nodes := []interface{}{node}
+	for _, step := range po {
+		nodes = step.apply(nodes)
+	}
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	return nodes[0], true
+}
+
+func (lo *literalOperand) eval(node interface{}) (interface{}, bool) { return lo.v, true }
+
+func (ne *notExpr) eval(node interface{}) (interface{}, bool) {
+	return !truthy(ne.x.eval(node)), true
+}
+
+func (le *logicalExpr) eval(node interface{}) (interface{}, bool) {
+	x := truthy(le.x.eval(node))
+	if le.and && !x || !le.and && x {
+		return x, true
+	}
+	return truthy(le.y.eval(node)), true
+}
+
+func (ce *compareExpr) eval(node interface{}) (interface{}, bool) {
+	x, xok := ce.x.eval(node)
+	y, yok := ce.y.eval(node)
+	if !xok || !yok {
+		return ce.op == "!=" && xok != yok, true
+	}
+	cmp, comparable := 0, true
+	xf, xnum := toFloat(x)
+	yf, ynum := toFloat(y)
+	xs, xstr := x.(string)
+	ys, ystr := y.(string)
+	switch {
+	case xnum && ynum:
+		if xf < yf {
+			cmp = -1
+		} else if xf > yf {
+			cmp = 1
+		}
+	case xstr && ystr:
+		cmp = strings.Compare(xs, ys)
+	default:
+		comparable = false
+		if !reflect.DeepEqual(x, y) {
+			cmp = 1
+		}
+	}
+	switch ce.op {
+	case "==":
+		return cmp == 0, true
+	case "!=":
+		return cmp != 0, true
+	case "<":
+		return comparable && cmp < 0, true
+	case "<=":
+		return comparable && cmp <= 0, true
+	case ">":
+		return comparable && cmp > 0, true
+	default:
+		return comparable && cmp >= 0, true
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// query is a compiled path, multi is true if the path can match more than one value.
+type query struct {
+	steps []queryStep
+	multi bool
+}
+
+type queryParser struct {
+	src string
+	pos int
+}
+
+func compileQuery(src string) (*query, error) {
+	p := &queryParser{src: src}
+	p.skipSpace()
+	// a JSONPath root $ is skipped, it is not documented since a Cookfile string interpolate it
+	if p.peek() == '$' {
+		p.pos++
+	} else if c := p.peek(); c != '.' && c != '[' && !p.isIdentStart() {
+		return nil, p.errorf("path must start with . or [")
+	}
+	q := &query{}
+	var err error
+	if q.steps, q.multi, err = p.parseSteps(); err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return q, nil
+}
+
+func (p *queryParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("invalid path %s at %d: %s", p.src, p.pos+1, fmt.Sprintf(format, a...))
+}
+
+func (p *queryParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *queryParser) isIdentStart() bool {
+	c := p.peek()
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func (p *queryParser) ident() string {
+	start := p.pos
+	for p.isIdentStart() || p.pos > start && ('0' <= p.peek() && p.peek() <= '9' || p.peek() == '-') {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *queryParser) expect(c byte) error {
+	if p.skipSpace(); p.peek() != c {
+		return p.errorf("expect %c", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *queryParser) parseSteps() (steps []queryStep, multi bool, err error) {
+	for p.pos < len(p.src) {
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], ".."):
+			p.pos += 2
+			steps, multi = append(steps, recursiveStep{}), true
+			if p.isIdentStart() {
+				steps = append(steps, fieldStep(p.ident()))
+			} else if p.peek() == '*' {
+				p.pos++
+				steps = append(steps, wildcardStep{})
+			} else if p.peek() != '[' {
+				return nil, false, p.errorf("expect name, * or [ after ..")
+			}
+		case p.peek() == '.':
+			p.pos++
+			switch c := p.peek(); {
+			case c == '*':
+				p.pos++
+				steps, multi = append(steps, wildcardStep{}), true
+			case c == '"' || c == '\'':
+				s, err := p.parseString()
+				if err != nil {
+					return nil, false, err
+				}
+				steps = append(steps, fieldStep(s))
+			case p.isIdentStart():
+				steps = append(steps, fieldStep(p.ident()))
+			}
+			// . alone is the value itself and .[ is the same as [
+		case p.peek() == '[':
+			step, m, err := p.parseBracket()
+			if err != nil {
+				return nil, false, err
+			}
+			steps, multi = append(steps, step), multi || m
+		case len(steps) == 0 && p.isIdentStart():
+			steps = append(steps, fieldStep(p.ident()))
+		default:
+			return steps, multi, nil
+		}
+	}
+	return steps, multi, nil
+}
+
+func (p *queryParser) parseBracket() (queryStep, bool, error) {
+	p.pos++
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == ']':
+		p.pos++
+		return wildcardStep{}, true, nil
+	case c == '*':
+		p.pos++
+		return wildcardStep{}, true, p.expect(']')
+	case c == '?':
+		p.pos++
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, false, err
+		}
+		return filterStep{cond: cond}, true, p.expect(']')
+	case c == '"' || c == '\'':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, false, err
+		}
+		return fieldStep(s), false, p.expect(']')
+	}
+	s := sliceStep{}
+	var err error
+	if s.start, s.hasStart, err = p.parseInt(); err != nil {
+		return nil, false, err
+	}
+	if p.skipSpace(); p.peek() == ':' {
+		p.pos++
+		p.skipSpace()
+		if s.end, s.hasEnd, err = p.parseInt(); err != nil {
+			return nil, false, err
+		}
+		return s, true, p.expect(']')
+	} else if !s.hasStart {
+		return nil, false, p.errorf("expect index, slice, name, * or ? condition")
+	}
+	return indexStep(s.start), false, p.expect(']')
+}
+
+func (p *queryParser) parseInt() (int, bool, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for '0' <= p.peek() && p.peek() <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, false, nil
+	}
+	i, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		return 0, false, p.errorf("invalid index %s", p.src[start:p.pos])
+	}
+	return i, true, nil
+}
+
+func (p *queryParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	buf := &strings.Builder{}
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		if c == '\\' && p.pos+1 < len(p.src) {
+			p.pos++
+			buf.WriteByte(p.src[p.pos])
+		} else if c == quote {
+			p.pos++
+			return buf.String(), nil
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+	return "", p.errorf("missing closing quote %c", quote)
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	x, err := p.parseAnd()
+	for err == nil {
+		if p.skipSpace(); !strings.HasPrefix(p.src[p.pos:], "||") {
+			return x, nil
+		}
+		p.pos += 2
+		var y queryExpr
+		if y, err = p.parseAnd(); err == nil {
+			x = &logicalExpr{x: x, y: y}
+		}
+	}
+	return nil, err
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	x, err := p.parseUnary()
+	for err == nil {
+		if p.skipSpace(); !strings.HasPrefix(p.src[p.pos:], "&&") {
+			return x, nil
+		}
+		p.pos += 2
+		var y queryExpr
+		if y, err = p.parseUnary(); err == nil {
+			x = &logicalExpr{and: true, x: x, y: y}
+		}
+	}
+	return nil, err
+}
+
+func (p *queryParser) parseUnary() (queryExpr, error) {
+	p.skipSpace()
+	switch {
+	case p.peek() == '!' && !strings.HasPrefix(p.src[p.pos:], "!="):
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{x: x}, nil
+	case p.peek() == '(':
+		p.pos++
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(')')
+	}
+	x, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			y, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &compareExpr{op: op, x: x, y: y}, nil
+		}
+	}
+	return x, nil
+}
+
+func (p *queryParser) parseOperand() (queryExpr, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '@' || c == '.':
+		if c == '@' {
+			p.pos++
+		}
+		steps, _, err := p.parseSteps()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand(steps), nil
+	case c == '"' || c == '\'':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return &literalOperand{v: s}, nil
+	case c == '-' || '0' <= c && c <= '9':
+		start := p.pos
+		for p.pos++; p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0; p.pos++ {
+		}
+		lit := p.src[start:p.pos]
+		if i, err := strconv.ParseInt(lit, 10, 64); err == nil {
+			return &literalOperand{v: i}, nil
+		} else if f, err := strconv.ParseFloat(lit, 64); err == nil {
+			return &literalOperand{v: f}, nil
+		}
+		return nil, p.errorf("invalid number %s", lit)
+	case p.isIdentStart():
+		switch word := p.ident(); word {
+		case "true", "false":
+			return &literalOperand{v: word == "true"}, nil
+		default:
+			return nil, p.errorf("unexpected %s, a path in condition must start with @", word)
+		}
+	}
+	return nil, p.errorf("expect a path starting with @ or a literal")
+}
+
+// Query return the values found by path in value, see function query for the syntax of the path.
+func Query(path string, value interface{}) (interface{}, bool, error) {
+	q, err := compileQuery(path)
+	if err != nil {
+		return nil, false, err
+	}
+	nodes := []interface{}{value}
+	for _, step := range q.steps {
+		nodes = step.apply(nodes)
+	}
+	if q.multi {
+		if nodes == nil {
+			nodes = []interface{}{}
+		}
+		return nodes, true, nil
+	} else if len(nodes) == 0 {
+		return nil, false, nil
+	}
+	return nodes[0], true, nil
+}
+
+func init() {
+	registerReadOnlyFunction(NewBaseFunction(queryFlags, func(f Function, i interface{}) (interface{}, error) {
+		opts := i.(*queryOptions)
+		if len(opts.Args) < 2 {
+			return nil, fmt.Errorf("%s require a path and a value", f.Name())
+		}
+		path, ok := opts.Args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s path must be a string, given %v", f.Name(), opts.Args[0])
+		}
+		// an array is given as a single argument, only more than one value form a new array
+		var value interface{} = opts.Args[1:]
+		if len(opts.Args) == 2 {
+			value = opts.Args[1]
+		}
+		v, found, err := Query(path, value)
+		if err != nil {
+			return nil, err
+		} else if found {
+			return v, nil
+		} else if opts.Default != nil {
+			return opts.Default, nil
+		}
+		return nil, fmt.Errorf("path %s does not match any value", path)
+	}))
+}
@@ -0,0 +1,80 @@
+package function
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cozees/cook/pkg/runtime/args"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var queryData = map[interface{}]interface{}{
+	"name": "cook",
+	"items": []interface{}{
+		map[interface{}]interface{}{"name": "a", "size": int64(5), "tags": []interface{}{"x"}},
+		map[interface{}]interface{}{"name": "b", "size": int64(20), "stable": true},
+		map[interface{}]interface{}{"name": "c", "size": 30.5, "stable": false},
+	},
+	"server": map[interface{}]interface{}{"name": "srv", "port": int64(8080), "the host": "localhost"},
+	int64(1): "one",
+}
+
+func queryArgs(path string, flags ...string) []*args.FunctionArg {
+	fas := convertToFunctionArgs(append(flags, path))
+	return append(fas, &args.FunctionArg{Val: queryData, Kind: reflect.Map})
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		args   []*args.FunctionArg
+		output interface{}
+		err    bool
+	}{
+		{args: queryArgs("."), output: queryData},                                                           // case 1
+		{args: queryArgs(".name"), output: "cook"},                                                          // case 2
+		{args: queryArgs("$.server.port"), output: int64(8080)},                                             // case 3
+		{args: queryArgs(`.server["the host"]`), output: "localhost"},                                       // case 4
+		{args: queryArgs(`.server."the host"`), output: "localhost"},                                        // case 5
+		{args: queryArgs(".items[-1].name"), output: "c"},                                                   // case 6
+		{args: queryArgs(".[1]"), output: "one"},                                                            // case 7
+		{args: queryArgs(".items[*].name"), output: []interface{}{"a", "b", "c"}},                           // case 8
+		{args: queryArgs(".items[].size"), output: []interface{}{int64(5), int64(20), 30.5}},                // case 9
+		{args: queryArgs(".items[1:].name"), output: []interface{}{"b", "c"}},                               // case 10
+		{args: queryArgs(".items[:-2].name"), output: []interface{}{"a"}},                                   // case 11
+		{args: queryArgs("..name"), output: []interface{}{"cook", "a", "b", "c", "srv"}},                    // case 12
+		{args: queryArgs(".items[?(@.size > 10)].name"), output: []interface{}{"b", "c"}},                   // case 13
+		{args: queryArgs(".items[?@.stable].name"), output: []interface{}{"b"}},                             // case 14
+		{args: queryArgs(".items[?(!@.stable && @.size < 10)].name"), output: []interface{}{"a"}},           // case 15
+		{args: queryArgs(`.items[?(@.name == "a" || @.size >= 30)].name`), output: []interface{}{"a", "c"}}, // case 16
+		{args: queryArgs(".items[?(@.tags)].tags[0]"), output: []interface{}{"x"}},                          // case 17
+		{args: queryArgs(".items[?(@.size > 100)].name"), output: []interface{}{}},                          // case 18
+		{args: queryArgs(".missing"), err: true},                                                            // case 19
+		{args: queryArgs(".missing", "-d", "none"), output: "none"},                                         // case 20
+		{args: queryArgs(".items[0"), err: true},                                                            // case 21
+		{args: queryArgs(".items[?(size > 1)]"), err: true},                                                 // case 22
+		{args: queryArgs("name]"), err: true},                                                               // case 23
+		{ // case 24
+			args:   []*args.FunctionArg{{Val: "[1]", Kind: reflect.String}, {Val: int64(1), Kind: reflect.Int64}, {Val: int64(2), Kind: reflect.Int64}},
+			output: int64(2),
+		},
+		{ // case 25
+			args:   append([]*args.FunctionArg{{Val: "-d", Kind: reflect.String}, {Val: 2.5, Kind: reflect.Float64}}, queryArgs(".server.host")...),
+			output: 2.5,
+		},
+		{ // case 26
+			args:   []*args.FunctionArg{{Val: ".[0]", Kind: reflect.String}, {Val: []interface{}{int64(7)}, Kind: reflect.Slice}},
+			output: int64(7),
+		},
+	}
+	for i, tc := range tests {
+		t.Logf("TestQuery case #%d", i+1)
+		result, err := GetFunction("query").Apply(tc.args)
+		if tc.err {
+			assert.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.output, result)
+	}
+}
@@ -238,7 +238,7 @@ func pad(left, right, max int, by, arg string) string {
 }
 
 func init() {
-	registerFunction(NewBaseFunction(spadFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(spadFlags, func(f Function, i interface{}) (interface{}, error) {
 		opts := i.(*sPadOptions)
 		switch si := len(opts.Args); si {
 		case 0:
@@ -268,7 +268,7 @@ func init() {
 		}
 	}))
 
-	registerFunction(NewBaseFunction(ssplitFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(ssplitFlags, func(f Function, i interface{}) (interface{}, error) {
 		opts := i.(*sSplitOption)
 		// validate the argument
 		if len(opts.Args) > 1 || len(opts.Args) == 0 {
@@ -442,7 +442,7 @@ func init() {
 		}
 	}))
 
-	registerFunction(NewBaseFunction(sreplaceFlags, func(f Function, i interface{}) (interface{}, error) {
+	registerReadOnlyFunction(NewBaseFunction(sreplaceFlags, func(f Function, i interface{}) (interface{}, error) {
 		opts := i.(*sReplaceOption)
 		numArgs := len(opts.Args)
 		if numArgs != 3 && numArgs != 4 {
@@ -0,0 +1,101 @@
+package function
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/cozees/cook/pkg/runtime/args"
+)
+
+func AllTemplateFlags() []*args.Flags {
+	return []*args.Flags{templateFlags}
+}
+
+type templateOptions struct {
+	Includes []string `flag:"include"`
+	Args     []interface{}
+}
+
+const (
+	templateDesc = `Returns the text of the template file rendered with the given value as data. The template use
+					the syntax of Go text/template, thus {{.name}} write the key name of the data map, {{if}},
+					{{else}} and {{range}} render a part of the template conditionally or for each element of an
+					array or a map and {{template "header.tmpl" .}} render the included file header.tmpl or a
+					template defined with {{define}}. Every function which does not modify the file system, the
+					network or the standard output is available in the template by its name, for example
+					{{jsonencode .deps}} or {{query ".items[0].name" .}}.`
+	templateIncludeDesc = `Parse the file along with the template so it can be render by its file name or by the name of the
+						   templates it define. The flag can be given multiple times.`
+)
+
+var templateFlags = &args.Flags{
+	Flags: []*args.Flag{
+		{Short: "i", Long: "include", Description: templateIncludeDesc},
+	},
+	Result:      reflect.TypeOf((*templateOptions)(nil)).Elem(),
+	FuncName:    "template",
+	KeepArray:   true,
+	ShortDesc:   "render a template file with a value as data.",
+	Usage:       "@template [-i FILE]... FILE [DATA]",
+	Example:     "@template -i header.tmpl nginx.conf.tmpl config > nginx.conf",
+	Description: templateDesc,
+}
+
+// templateFuncs return every read only function as a template function.
+func templateFuncs() template.FuncMap {
+	fm := template.FuncMap{}
+	for name, fn := range funcStore {
+		if !IsReadOnly(fn) {
+			continue
+		}
+		f := fn
+		fm[name] = func(a ...interface{}) (interface{}, error) {
+			fas := make([]*args.FunctionArg, len(a))
+			for i, v := range a {
+				// literal number in a template is an int or a float64 while cook use int64 and float64
+				switch n := reflect.ValueOf(v); n.Kind() {
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+					v = n.Int()
+				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+					v = int64(n.Uint())
+				case reflect.Float32:
+					v = n.Float()
+				}
+				fas[i] = &args.FunctionArg{Val: v, Kind: reflect.ValueOf(v).Kind()}
+			}
+			return f.Apply(fas)
+		}
+	}
+	return fm
+}
+
+func init() {
+	registerReadOnlyFunction(NewBaseFunction(templateFlags, func(f Function, i interface{}) (interface{}, error) {
+		opts := i.(*templateOptions)
+		if len(opts.Args) == 0 {
+			return nil, fmt.Errorf("%s require a template file", f.Name())
+		}
+		file, ok := opts.Args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s template file must be a string, given %v", f.Name(), opts.Args[0])
+		}
+		var data interface{}
+		if len(opts.Args) == 2 {
+			data = opts.Args[1]
+		} else if len(opts.Args) > 2 {
+			data = opts.Args[1:]
+		}
+		tmpl, err := template.New(filepath.Base(file)).Funcs(templateFuncs()).ParseFiles(append([]string{file}, opts.Includes...)...)
+		if err != nil {
+			return nil, err
+		}
+		buf := &strings.Builder{}
+		if err = tmpl.Execute(buf, data); err != nil {
+			return nil, err
+		}
+		return buf.String(), nil
+	}))
+}
@@ -0,0 +1,76 @@
+package function
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cozees/cook/pkg/runtime/args"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"header.tmpl": `# {{.name}} {{spad "-l" 2 "--by" "=" "v"}}{{.version}}`,
+		"conf.tmpl":   "{{template \"header.tmpl\" .}}\n{{range .servers}}{{if .enable}}server {{.host}}:{{.port}}\n{{end}}{{end}}{{jsonencode .tags}}",
+		"query.tmpl":  `{{query ".servers[?(@.port > 8000)].host" .}} {{len .servers}} {{pbase "/a/b.txt"}}`,
+		"list.tmpl":   `{{range $i, $v := .}}{{$i}}={{$v}} {{end}}`,
+		"print.tmpl":  `{{print .}}{{mkdir "x"}}`,
+		"bad.tmpl":    `{{.name`,
+	}
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	data := &args.FunctionArg{Kind: reflect.Map, Val: map[interface{}]interface{}{
+		"name":    "cook",
+		"version": "1.0",
+		"tags":    []interface{}{"a", "b"},
+		"servers": []interface{}{
+			map[interface{}]interface{}{"host": "localhost", "port": int64(8080), "enable": true},
+			map[interface{}]interface{}{"host": "remote", "port": int64(22), "enable": false},
+			map[interface{}]interface{}{"host": "backup", "port": int64(8081), "enable": true},
+		},
+	}}
+	file := func(name string) *args.FunctionArg {
+		return &args.FunctionArg{Val: filepath.Join(dir, name), Kind: reflect.String}
+	}
+	tests := []struct {
+		args   []*args.FunctionArg
+		output interface{}
+		err    bool
+	}{
+		{ // case 1
+			args:   []*args.FunctionArg{{Val: "-i", Kind: reflect.String}, file("header.tmpl"), file("conf.tmpl"), data},
+			output: "# cook ==v1.0\nserver localhost:8080\nserver backup:8081\n[\"a\",\"b\"]",
+		},
+		{ // case 2
+			args:   []*args.FunctionArg{file("query.tmpl"), data},
+			output: "[localhost backup] 3 b.txt",
+		},
+		{ // case 3
+			args:   []*args.FunctionArg{file("list.tmpl"), {Val: int64(1), Kind: reflect.Int64}, {Val: "x", Kind: reflect.String}},
+			output: "0=1 1=x ",
+		},
+		{args: []*args.FunctionArg{file("conf.tmpl"), data}, err: true},  // case 4
+		{args: []*args.FunctionArg{file("print.tmpl"), data}, err: true}, // case 5
+		{args: []*args.FunctionArg{file("bad.tmpl"), data}, err: true},   // case 6
+		{args: []*args.FunctionArg{file("missing.tmpl")}, err: true},     // case 7
+		{ // case 8
+			args:   []*args.FunctionArg{file("list.tmpl"), {Val: []interface{}{"only"}, Kind: reflect.Slice}},
+			output: "0=only ",
+		},
+	}
+	for i, tc := range tests {
+		t.Logf("TestTemplate case #%d", i+1)
+		result, err := GetFunction("template").Apply(tc.args)
+		if tc.err {
+			assert.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.output, result)
+	}
+}
@@ -0,0 +1,115 @@
+package glob
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const metaChars = "*?["
+
+// HasMeta report whether the pattern contain any glob special character.
+func HasMeta(pattern string) bool { return strings.ContainsAny(pattern, metaChars) }
+
+// Glob return the names of all files matching pattern or nil if there is no matching file. The
+// pattern syntax is the same as filepath.Match with an addition of ** which match zero or more
+// directories, e.g. src/**/*.go match every go file under folder src.
+func Glob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	for _, seg := range segments {
+		// validate pattern before walking through the file system
+		if seg != "**" {
+			if _, err := filepath.Match(seg, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// walk from the deepest folder which does not contain any special character
+	i := 0
+	for ; i < len(segments) && !HasMeta(segments[i]); i++ {
+	}
+	root := strings.Join(segments[:i], "/")
+	if root == "" && strings.HasPrefix(pattern, "/") {
+		root = "/"
+	}
+	walkRoot := root
+	if walkRoot == "" {
+		walkRoot = "."
+	}
+	patterns := segments[i:]
+	var matches []string
+	err := filepath.Walk(filepath.FromSlash(walkRoot), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// ignore file which cannot be read similar to filepath.Glob
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(walkRoot), path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		if matchSegments(patterns, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func matchSegments(patterns, names []string) bool {
+	if len(patterns) == 0 {
+		return len(names) == 0
+	} else if patterns[0] == "**" {
+		return matchSegments(patterns[1:], names) || (len(names) > 0 && matchSegments(patterns, names[1:]))
+	} else if len(names) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(patterns[0], names[0])
+	return ok && matchSegments(patterns[1:], names[1:])
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Snapshot hold modification time and size of files matched by a set of glob pattern.
+type Snapshot map[string]fileState
+
+// Take record the state of every file matched by the patterns.
+func Take(patterns ...string) (Snapshot, error) {
+	s := make(Snapshot)
+	for _, pattern := range patterns {
+		files, err := Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if stat, err := os.Stat(file); err == nil && !stat.IsDir() {
+				s[file] = fileState{modTime: stat.ModTime(), size: stat.Size()}
+			}
+		}
+	}
+	return s, nil
+}
+
+// Changed return true if a file was added, removed or modified since the snapshot o was taken.
+func (s Snapshot) Changed(o Snapshot) bool {
+	if len(s) != len(o) {
+		return true
+	}
+	for file, st := range s {
+		if ost, ok := o[file]; !ok || !ost.modTime.Equal(st.modTime) || ost.size != st.size {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package glob
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createFiles(t *testing.T, dir string, files ...string) {
+	for _, file := range files {
+		file = filepath.Join(dir, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0700))
+		require.NoError(t, ioutil.WriteFile(file, []byte(file), 0600))
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	createFiles(t, dir, "a.go", "a.txt", "src/b.go", "src/x/c.go", "src/x/y/d.go", "src/x/y/e.txt")
+
+	join := func(files ...string) []string {
+		for i := range files {
+			files[i] = filepath.Join(dir, filepath.FromSlash(files[i]))
+		}
+		return files
+	}
+	cases := []struct {
+		pattern string
+		matches []string
+	}{
+		{"*.go", join("a.go")},
+		{"src/*.go", join("src/b.go")},
+		{"src/**/*.go", join("src/b.go", "src/x/c.go", "src/x/y/d.go")},
+		{"**/*.txt", join("a.txt", "src/x/y/e.txt")},
+		{"src/**/y/*", join("src/x/y/d.go", "src/x/y/e.txt")},
+		{"src/x/**", join("src/x/c.go", "src/x/y", "src/x/y/d.go", "src/x/y/e.txt")},
+		{"**/*.md", nil},
+	}
+	for i, tc := range cases {
+		t.Logf("TestGlob case #%d", i+1)
+		matches, err := Glob(filepath.Join(dir, tc.pattern))
+		require.NoError(t, err)
+		assert.Equal(t, tc.matches, matches)
+	}
+	_, err := Glob(filepath.Join(dir, "**", "[a.go"))
+	assert.Error(t, err)
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	createFiles(t, dir, "src/a.go", "src/x/b.go")
+	pattern := filepath.Join(dir, "src", "**", "*.go")
+
+	s1, err := Take(pattern)
+	require.NoError(t, err)
+	assert.Len(t, s1, 2)
+	s2, err := Take(pattern)
+	require.NoError(t, err)
+	assert.False(t, s2.Changed(s1))
+
+	// modified
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "src", "a.go"), future, future))
+	s3, err := Take(pattern)
+	require.NoError(t, err)
+	assert.True(t, s3.Changed(s2))
+
+	// added
+	createFiles(t, dir, "src/x/y/c.go")
+	s4, err := Take(pattern)
+	require.NoError(t, err)
+	assert.True(t, s4.Changed(s3))
+
+	// removed
+	require.NoError(t, os.Remove(filepath.Join(dir, "src", "x", "b.go")))
+	s5, err := Take(pattern)
+	require.NoError(t, err)
+	assert.True(t, s5.Changed(s4))
+	assert.Len(t, s5, 2)
+}
@@ -76,7 +76,7 @@ func cleanup() {
 }
 
 func buildNative() error {
-	cmd := exec.Command("go", "build", "-ldflags=-s -w", "-o", executableName(cookRawExec), "../cmd/main.go", "../cmd/help.go")
+	cmd := exec.Command("go", "build", "-ldflags=-s -w", "-o", executableName(cookRawExec), "../cmd")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
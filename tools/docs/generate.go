@@ -28,6 +28,10 @@ const (
 	httpDesc     = `Http functions provide pre-define function to send get, head, options, post, patch, put and delete request to the server.`
 	logDesc      = `Log functions provide several pre-define functionality print or format variable to the standard output.`
 	pathDesc     = `Path functions provide several pre-define functionality that can be use to manipulate or extract metadata from file path.`
+	encodingDesc = `Encoding functions provide pre-define function to parse or encode JSON, YAML and TOML document.`
+	queryDesc    = `Query functions provide pre-define function to find values in nested maps and arrays by a path.`
+	templateDesc = `Template functions provide pre-define function to render a template file with a value as data.`
+	envDesc      = `Environment functions provide pre-define function to load environment variables from a file.`
 	fdDesc       = `File and Directory functions provide several pre-define functionality create, delete or modified ones or more files and directories.`
 )
 
@@ -38,6 +42,10 @@ var functions = []*functionGroup{
 	{Name: "Log Functions", File: "log", Flags: function.AllLogFlags, Description: logDesc},
 	{Name: "Path Functions", File: "path", Flags: function.AllPathFlags, Description: pathDesc},
 	{Name: "File and Directory Functions", File: "fd", Flags: function.AllFileDirectoryFlags, Description: fdDesc},
+	{Name: "Environment Functions", File: "env", Flags: function.AllEnvFlags, Description: envDesc},
+	{Name: "Encoding Functions", File: "encoding", Flags: function.AllEncodingFlags, Description: encodingDesc},
+	{Name: "Query Functions", File: "query", Flags: function.AllQueryFlags, Description: queryDesc},
+	{Name: "Template Functions", File: "template", Flags: function.AllTemplateFlags, Description: templateDesc},
 }
 
 func main() {